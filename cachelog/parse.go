@@ -0,0 +1,300 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cachelog parses the log written by the go command's build cache.
+//
+// The log is the file log.txt in the cache directory reported by
+// "go env GOCACHE". Each line records one cache operation; see Parser.Parse
+// for the format. ParseLog reads a whole log with the default settings;
+// a Parser offers more control, such as streaming records with Scan
+// or skipping malformed lines.
+//
+// Analyze computes statistics from the parsed records, which may first be
+// filtered or merged from several logs:
+//
+//	records, err := cachelog.ParseLog(f)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	s := cachelog.Analyze(records)
+//	fmt.Printf("%d of %d data bytes reused\n", s.Data.Reused, s.Data.Total)
+package cachelog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Verb is the kind of operation recorded by a log line.
+type Verb int
+
+const (
+	Unknown Verb = iota // an unrecognized verb, perhaps from a newer go command
+	Put                 // an entry was written to the cache
+	Get                 // an entry was found in the cache
+	Miss                // an entry was not found in the cache
+	Trim                // the cache was cleaned up
+)
+
+var verbNames = []string{
+	Unknown: "unknown",
+	Put:     "put",
+	Get:     "get",
+	Miss:    "miss",
+	Trim:    "trim",
+}
+
+// String returns the verb as written in the log, like "put",
+// or "unknown" for Unknown.
+func (v Verb) String() string {
+	if v < 0 || int(v) >= len(verbNames) {
+		return fmt.Sprintf("Verb(%d)", int(v))
+	}
+	return verbNames[v]
+}
+
+// parseVerb returns the Verb written as s in the log.
+func parseVerb(s string) Verb {
+	for v, name := range verbNames {
+		if Verb(v) != Unknown && name == s {
+			return Verb(v)
+		}
+	}
+	return Unknown
+}
+
+// A Record is a single event in the cache log.
+type Record struct {
+	Time     time.Time // time of the event, to the second
+	Verb     Verb
+	ActionID string // action ID (not trim)
+	OutputID string // output ID (put only)
+	Size     int64  // output size in bytes (put), or bytes reclaimed (trim)
+
+	// ActionSize is the size of the action entry in bytes (put only),
+	// or 0 if the log does not record it.
+	ActionSize int64
+
+	Source string // log the record came from, from Parser.Source
+}
+
+// ParseLog reads a cache log from r and returns its records,
+// as a Parser with default settings would.
+// Errors identify lines by number, as in "log:12: invalid time: ...".
+func ParseLog(r io.Reader) ([]Record, error) {
+	p := &Parser{Name: "log"}
+	return p.Parse(r)
+}
+
+// maxLine is the maximum length of a log line.
+const maxLine = 1 << 20
+
+// A putLayout gives the field indexes in one known layout of put lines.
+// The time, verb, and action ID are always fields 0, 1, and 2.
+type putLayout struct {
+	output     int // output ID
+	size       int // output size
+	actionSize int // action entry size, or 0 if absent
+}
+
+// putLayouts maps the field count of a put line to its layout.
+var putLayouts = map[int]putLayout{
+	5: {output: 3, size: 4},                // Go 1.10
+	6: {output: 3, size: 4, actionSize: 5}, // with action entry size
+}
+
+// A Parser parses cache logs.
+type Parser struct {
+	// Name is the name of the log, used in error messages.
+	Name string
+
+	// Source tags the parsed records, to distinguish
+	// records from different logs once they are merged.
+	Source string
+
+	// Progress, if non-nil, is called periodically during Parse
+	// with the number of lines read so far.
+	Progress func(lines int)
+
+	// Warn, if non-nil, is called with a message about lines
+	// that Parse skips because it does not recognize their format.
+	Warn func(msg string)
+
+	// Lenient makes Parse skip malformed lines, counting them
+	// in Malformed and reporting each to Warn, instead of
+	// failing at the first one.
+	Lenient   bool
+	Malformed int
+
+	// MaxLines, if positive, makes Parse stop after reading
+	// that many lines, setting Truncated if the log had more.
+	MaxLines  int
+	Truncated bool
+
+	// Lines is the number of lines read by the last Parse.
+	Lines int
+
+	// LineOffset is added to the line numbers in messages,
+	// for a log read in pieces, such as one that is still growing.
+	LineOffset int
+
+	warned map[int]bool // put field counts already warned about
+}
+
+// progressLines is the number of lines between calls to Parser.Progress.
+const progressLines = 1 << 16
+
+// Parse reads a cache log from r and returns its records.
+//
+// Each line of the log has the form
+//
+//	time verb actionID [outputID size [actionSize]]
+//
+// where outputID and size appear only in put lines.
+// Trim lines, which record cache cleanups, have the form
+//
+//	time trim [size]
+//
+// where the optional size is the number of bytes reclaimed.
+// The Go 1.10 go command writes put lines without actionSize;
+// logs that carry it as a sixth field record the actual size
+// of each action entry. Put lines with any other number of fields
+// presumably come from a newer format: Parse skips them,
+// calling p.Warn once for each unexpected field count.
+//
+// If the log is gzip-compressed, Parse decompresses it.
+// Blank lines are ignored. Lines may end in \r\n as well as \n,
+// since bufio.ScanLines drops the \r.
+func (p *Parser) Parse(r io.Reader) ([]Record, error) {
+	var records []Record
+	err := p.Scan(r, func(rec Record) {
+		records = append(records, rec)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Scan reads a cache log from r, as in Parse, calling fn for each record
+// instead of accumulating them, so that memory use stays bounded.
+func (p *Parser) Scan(r io.Reader, fn func(Record)) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("%s: %v", p.Name, err)
+		}
+		r = zr
+	} else {
+		r = br
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), maxLine)
+	p.Lines, p.Truncated = 0, false
+	lines := 0
+	if p.warned == nil {
+		p.warned = make(map[int]bool)
+	}
+	for s.Scan() {
+		if p.MaxLines > 0 && lines >= p.MaxLines {
+			p.Truncated = true
+			break
+		}
+		lines++
+		p.Lines = lines
+		if p.Progress != nil && lines%progressLines == 0 {
+			p.Progress(lines)
+		}
+		line := s.Text()
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) >= 2 && f[1] == "trim" && len(f) <= 3 {
+			t, err1 := strconv.ParseInt(f[0], 10, 64)
+			var size int64
+			var err2 error
+			if len(f) == 3 {
+				size, err2 = strconv.ParseInt(f[2], 10, 64)
+			}
+			if err1 != nil || err2 != nil {
+				if err := p.malformed(lines, "invalid trim", line); err != nil {
+					return err
+				}
+				continue
+			}
+			fn(Record{Time: time.Unix(t, 0), Verb: Trim, Size: size, Source: p.Source})
+			continue
+		}
+		if len(f) < 3 {
+			if err := p.malformed(lines, "invalid line", line); err != nil {
+				return err
+			}
+			continue
+		}
+		layout, known := putLayouts[len(f)]
+		if f[1] == "put" && !known {
+			if !p.warned[len(f)] && p.Warn != nil {
+				p.Warn(fmt.Sprintf("%s:%d: skipping put lines with %d fields (want 5 or 6), like: %s", p.Name, p.LineOffset+lines, len(f), line))
+			}
+			p.warned[len(f)] = true
+			continue
+		}
+		t, err := strconv.ParseInt(f[0], 10, 64)
+		if err != nil {
+			if err := p.malformed(lines, "invalid time", line); err != nil {
+				return err
+			}
+			continue
+		}
+		rec := Record{Time: time.Unix(t, 0), Verb: parseVerb(f[1]), ActionID: f[2], Source: p.Source}
+		if rec.Verb == Put {
+			size, err := strconv.ParseInt(f[layout.size], 10, 64)
+			if err != nil {
+				if err := p.malformed(lines, "invalid size", line); err != nil {
+					return err
+				}
+				continue
+			}
+			rec.OutputID = f[layout.output]
+			rec.Size = size
+			if layout.actionSize > 0 {
+				rec.ActionSize, err = strconv.ParseInt(f[layout.actionSize], 10, 64)
+				if err != nil {
+					if err := p.malformed(lines, "invalid action size", line); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+		}
+		fn(rec)
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("%s: %v", p.Name, err)
+	}
+	return nil
+}
+
+// malformed handles the malformed line numbered n.
+// It returns an error describing the line,
+// or, if p.Lenient is set, counts and reports the line and returns nil.
+func (p *Parser) malformed(n int, problem, line string) error {
+	err := fmt.Errorf("%s:%d: %s: %s", p.Name, p.LineOffset+n, problem, line)
+	if !p.Lenient {
+		return err
+	}
+	p.Malformed++
+	if p.Warn != nil {
+		p.Warn("skipping " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cachelog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLog = `1000 put a1 d1 5000
+1100 put a2 d2 3000
+1200 put a3 d1 5000
+2000 get a1
+3000 miss a9
+4000 get a2
+5000 get a1
+`
+
+func TestParseLog(t *testing.T) {
+	records, err := ParseLog(strings.NewReader(testLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 7 {
+		t.Fatalf("ParseLog returned %d records, want 7", len(records))
+	}
+	want := Record{Time: time.Unix(1000, 0), Verb: Put, ActionID: "a1", OutputID: "d1", Size: 5000}
+	if records[0] != want {
+		t.Errorf("records[0] = %+v, want %+v", records[0], want)
+	}
+	_, err = ParseLog(strings.NewReader(testLog + "x get a1\n"))
+	if err == nil || err.Error() != "log:8: invalid time: x get a1" {
+		t.Errorf("ParseLog(bad time): err = %v", err)
+	}
+}
+
+var parseErrorTests = []struct {
+	line string
+	err  string
+}{
+	{"1000 get", "test:8: invalid line: 1000 get"},
+	{"x get a1", "test:8: invalid time: x get a1"},
+	{"1000 put a1 d1 big", "test:8: invalid size: 1000 put a1 d1 big"},
+	{"1000 put a1 d1 10 big", "test:8: invalid action size: 1000 put a1 d1 10 big"},
+}
+
+func TestParseError(t *testing.T) {
+	for _, tt := range parseErrorTests {
+		p := &Parser{Name: "test"}
+		_, err := p.Parse(strings.NewReader(testLog + tt.line + "\n"))
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("Parse(%q): err = %v, want %q", tt.line, err, tt.err)
+		}
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	var in string
+	for _, tt := range parseErrorTests {
+		in += tt.line + "\n"
+	}
+	p := &Parser{Name: "test", Lenient: true}
+	records, err := p.Parse(strings.NewReader(testLog + in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 7 || p.Malformed != len(parseErrorTests) {
+		t.Errorf("len(records) = %d, Malformed = %d, want 7, %d", len(records), p.Malformed, len(parseErrorTests))
+	}
+}
+
+func TestParseMaxLines(t *testing.T) {
+	for _, n := range []int{3, 7, 10} {
+		p := &Parser{Name: "test", MaxLines: n}
+		records, err := p.Parse(strings.NewReader(testLog))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := n
+		if want > 7 {
+			want = 7
+		}
+		if len(records) != want || p.Truncated != (n < 7) {
+			t.Errorf("MaxLines=%d: %d records, Truncated=%v", n, len(records), p.Truncated)
+		}
+	}
+}
+
+func TestParseUnknownPut(t *testing.T) {
+	var warnings []string
+	p := &Parser{Name: "test", Warn: func(msg string) { warnings = append(warnings, msg) }}
+	in := testLog + "1000 put a1 d1\n1000 put a1 d1 10 20 30\n1000 put a2 d2 10 20 30\n"
+	records, err := p.Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 7 {
+		t.Errorf("len(records) = %d, want 7", len(records))
+	}
+	want := []string{
+		"test:8: skipping put lines with 4 fields (want 5 or 6), like: 1000 put a1 d1",
+		"test:9: skipping put lines with 7 fields (want 5 or 6), like: 1000 put a1 d1 10 20 30",
+	}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings = %q, want %q", warnings, want)
+	}
+}
+
+func TestParseTrim(t *testing.T) {
+	p := &Parser{Name: "test"}
+	records, err := p.Parse(strings.NewReader("1000 trim\n2000 trim 4096\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{{Time: time.Unix(1000, 0), Verb: Trim}, {Time: time.Unix(2000, 0), Verb: Trim, Size: 4096}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %+v, want %+v", records, want)
+	}
+	if _, err := p.Parse(strings.NewReader("1000 trim x\n")); err == nil || err.Error() != "test:1: invalid trim: 1000 trim x" {
+		t.Errorf("Parse(bad trim): err = %v", err)
+	}
+}
+
+var putLayoutTests = []struct {
+	file       string
+	actionSize int64
+}{
+	{"testdata/put5.txt", 0},
+	{"testdata/put6.txt", 200},
+}
+
+func TestParsePutLayouts(t *testing.T) {
+	for _, tt := range putLayoutTests {
+		data, err := ioutil.ReadFile(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := &Parser{Name: tt.file}
+		records, err := p.Parse(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%v", err)
+			continue
+		}
+		want := []Record{
+			{Time: time.Unix(1000, 0), Verb: Put, ActionID: "a1", OutputID: "d1", Size: 5000, ActionSize: tt.actionSize},
+			{Time: time.Unix(1500, 0), Verb: Get, ActionID: "a1"},
+			{Time: time.Unix(2000, 0), Verb: Miss, ActionID: "a2"},
+		}
+		if !reflect.DeepEqual(records, want) {
+			t.Errorf("%s: records = %+v, want %+v", tt.file, records, want)
+		}
+	}
+}
+
+func TestParseCRLF(t *testing.T) {
+	p := &Parser{Name: "test"}
+	records, err := p.Parse(strings.NewReader(strings.Replace(testLog, "\n", "\r\n", -1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := p.Parse(strings.NewReader(testLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("CRLF records differ:\n%+v\nwant:\n%+v", records, want)
+	}
+	if records[0].Size != 5000 {
+		t.Errorf("size = %d, want 5000", records[0].Size)
+	}
+}
+
+func TestParseGzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(testLog))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p := &Parser{Name: "test.gz"}
+	records, err := p.Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ParseLog(strings.NewReader(testLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("gzip records differ:\n%+v\nwant:\n%+v", records, want)
+	}
+}
+
+func TestVerbString(t *testing.T) {
+	for _, v := range []Verb{Put, Get, Miss, Trim, Unknown} {
+		if v != Unknown && parseVerb(v.String()) != v {
+			t.Errorf("parseVerb(%q) = %v, want %v", v.String(), parseVerb(v.String()), v)
+		}
+	}
+	if s := Verb(42).String(); s != "Verb(42)" {
+		t.Errorf("Verb(42).String() = %q", s)
+	}
+	if v := parseVerb("unknown"); v != Unknown {
+		t.Errorf("parseVerb(unknown) = %v", v)
+	}
+}
+
+func BenchmarkParseLog(b *testing.B) {
+	data, err := ioutil.ReadAll(&logGen{n: 100000})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseLog(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func FuzzParseLog(f *testing.F) {
+	f.Add([]byte(testLog))
+	f.Add([]byte("1000 put a1 d1 5000 200\r\n1100 get a1\n"))
+	f.Add([]byte("1000 trim\n1100 trim 4096\n"))
+	f.Add([]byte("1000 put a1 d1\n1000 put a1 d1 5000 200 7\n"))
+	f.Add([]byte("x get a1\n1000 put a1 d1 y\n1000 get\n"))
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(testLog))
+	zw.Close()
+	f.Add(buf.Bytes())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		strict := &Parser{Name: "fuzz"}
+		records, err := strict.Parse(bytes.NewReader(data))
+		lenient := &Parser{Name: "fuzz", Lenient: true}
+		lrecords, lerr := lenient.Parse(bytes.NewReader(data))
+		if lerr != nil && err == nil {
+			t.Fatalf("lenient Parse failed but strict Parse did not: %v", lerr)
+		}
+		if err != nil {
+			return
+		}
+		if lenient.Malformed != 0 || !reflect.DeepEqual(records, lrecords) {
+			t.Fatalf("lenient Parse = %d records, %d malformed, want %d records, 0 malformed", len(lrecords), lenient.Malformed, len(records))
+		}
+		if len(records) > strict.Lines {
+			t.Fatalf("Parse returned %d records from %d lines", len(records), strict.Lines)
+		}
+		for _, rec := range records {
+			if rec.Verb == Put && (rec.ActionID == "" || rec.OutputID == "") {
+				t.Fatalf("Parse returned put with missing IDs: %+v", rec)
+			}
+		}
+		Analyze(records)
+	})
+}
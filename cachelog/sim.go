@@ -0,0 +1,203 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cachelog
+
+import (
+	"container/list"
+	"time"
+)
+
+// A SimResult is the result of simulating a cache eviction policy.
+type SimResult struct {
+	Hits        int   // gets that are still hits
+	Misses      int   // gets that become misses, plus the misses in the log
+	Lost        int   // gets that become misses
+	Evictions   int   // number of entries evicted
+	Evicted     int64 // bytes evicted
+	BytesServed int64 // bytes of data entries returned by hits
+	Peak        int64 // peak total size of present entries
+}
+
+// HitRate returns the fraction of simulated gets and misses that were hits,
+// or 0 if there were none.
+func (r SimResult) HitRate() float64 {
+	if r.Hits+r.Misses == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Hits+r.Misses)
+}
+
+// Count records the outcome of access a in r, where present reports
+// whether the simulated cache held the entries needed by a get.
+func (r *SimResult) Count(a Access, present bool) {
+	switch a.Verb {
+	case Get:
+		if present {
+			r.Hits++
+			r.BytesServed += a.Entry.Data.Size
+		} else {
+			r.Lost++
+			r.Misses++
+		}
+	case Miss:
+		r.Misses++
+	}
+}
+
+// A SimCache is a simulated cache holding entries in LRU order,
+// for use in simulations of eviction policies.
+type SimCache struct {
+	lru     *list.List // *resident, least recently used first
+	present map[*Entry]*list.Element
+	size    int64 // total size of present entries
+}
+
+// A resident is an entry present in a SimCache.
+type resident struct {
+	e    *Entry
+	last int64 // time of last use
+}
+
+// NewSimCache returns a new, empty SimCache.
+func NewSimCache() *SimCache {
+	return &SimCache{
+		lru:     list.New(),
+		present: make(map[*Entry]*list.Element),
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (c *SimCache) Len() int {
+	return c.lru.Len()
+}
+
+// Size returns the total size of the entries in the cache.
+func (c *SimCache) Size() int64 {
+	return c.size
+}
+
+// Has reports whether e is present in the cache.
+func (c *SimCache) Has(e *Entry) bool {
+	return c.present[e] != nil
+}
+
+// Touch records a use of e at time now, adding it to the cache if needed.
+func (c *SimCache) Touch(e *Entry, now int64) {
+	if elem := c.present[e]; elem != nil {
+		elem.Value.(*resident).last = now
+		c.lru.MoveToBack(elem)
+		return
+	}
+	c.present[e] = c.lru.PushBack(&resident{e, now})
+	c.size += e.Size
+}
+
+// Oldest returns the least recently used entry in the cache,
+// which must not be empty, and the time of its last use.
+func (c *SimCache) Oldest() (e *Entry, last int64) {
+	x := c.lru.Front().Value.(*resident)
+	return x.e, x.last
+}
+
+// EvictOldest evicts the least recently used entry in the cache,
+// which must not be empty, and returns it and the time of its last use.
+// If r is non-nil, EvictOldest counts the eviction in r.
+func (c *SimCache) EvictOldest(r *SimResult) (e *Entry, last int64) {
+	x := c.lru.Remove(c.lru.Front()).(*resident)
+	delete(c.present, x.e)
+	c.size -= x.e.Size
+	if r != nil {
+		r.Evictions++
+		r.Evicted += x.e.Size
+	}
+	return x.e, x.last
+}
+
+// Remove removes e, which must be present, from the cache.
+// It does not count as an eviction.
+func (c *SimCache) Remove(e *Entry) {
+	c.lru.Remove(c.present[e])
+	delete(c.present, e)
+	c.size -= e.Size
+}
+
+// HitRate returns the fraction of gets and misses that would have been
+// hits had the cache evicted every entry left unused for longer than ttl,
+// as simulated by SimulateTTL.
+func (s *Stats) HitRate(ttl time.Duration) float64 {
+	return s.SimulateTTL(ttl).HitRate()
+}
+
+// SimulateTTL replays the puts, gets, and misses in s under a policy
+// that evicts any action or data entry left unused for longer than ttl.
+// A get is a hit only if both its action entry and its data entry
+// were used (put, hit, or missed and rebuilt) within ttl of the get.
+// A miss in the log stays a miss; it rebuilds both entries,
+// as does a get that becomes a miss.
+//
+// Only operations on actions put in the log are simulated:
+// the log cannot say whether an entry put before it began
+// would have survived, so gets and misses of such entries,
+// including entries put before records filtered out by time,
+// are left out of the result.
+func (s *Stats) SimulateTTL(ttl time.Duration) SimResult {
+	sec := int64(ttl / time.Second)
+	var r SimResult
+	c := NewSimCache()
+	expire := func(now int64) {
+		for c.Len() > 0 {
+			if _, last := c.Oldest(); now-last <= sec {
+				break
+			}
+			c.EvictOldest(&r)
+		}
+	}
+	var now int64
+	for _, a := range s.Accesses {
+		now = a.Time
+		expire(now)
+		r.Count(a, c.Has(a.Entry) && c.Has(a.Entry.Data))
+		c.Touch(a.Entry, now)
+		c.Touch(a.Entry.Data, now)
+		if r.Peak < c.Size() {
+			r.Peak = c.Size()
+		}
+	}
+	expire(now)
+	return r
+}
+
+// SimulateLRU analyzes the records and simulates an LRU cache
+// holding at most capacity bytes of data, as in Stats.SimulateLRU.
+func SimulateLRU(records []Record, capacity int64) SimResult {
+	return Analyze(records).SimulateLRU(capacity)
+}
+
+// SimulateLRU replays the puts, gets, and misses in s under a policy
+// that keeps the total size of data entries at most capacity bytes,
+// evicting the least recently used data entries as needed.
+// Action entries are treated as pinned to their data entries:
+// a get is a hit if its data entry is still present.
+// A data entry larger than capacity is never cached.
+// As in SimulateTTL, only operations on actions put in the log are simulated.
+func (s *Stats) SimulateLRU(capacity int64) SimResult {
+	var r SimResult
+	c := NewSimCache()
+	for _, a := range s.Accesses {
+		d := a.Entry.Data
+		r.Count(a, c.Has(d))
+		if d.Size > capacity {
+			continue
+		}
+		c.Touch(d, a.Time)
+		for c.Size() > capacity {
+			c.EvictOldest(&r)
+		}
+		if r.Peak < c.Size() {
+			r.Peak = c.Size()
+		}
+	}
+	return r
+}
@@ -0,0 +1,126 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cachelog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// ttlLog puts two actions sharing a data entry and gets them
+// after the actions sat idle for 1000, 1800, and 2900 seconds.
+const ttlLog = `1000 put a1 d1 5000
+1100 put a2 d1 5000
+2000 get a1
+2900 get a2
+3000 miss a9
+4900 get a1
+4950 miss a2
+5000 get a3
+`
+
+var hitRateTests = []struct {
+	ttl  time.Duration
+	want float64
+}{
+	// Three gets of known actions and one miss of a known action;
+	// the miss of a9 and get of a3 are of actions never put.
+	{0, 0},
+	{900 * time.Second, 0},
+	{1000 * time.Second, 0.25}, // a1 at 2000 survives
+	{1800 * time.Second, 0.5},  // a2 at 2900 also survives
+	{2000 * time.Second, 0.5},  // d1 survives until 4900, but a1 does not
+	{2900 * time.Second, 0.75}, // every get survives
+	{1000 * time.Hour, 0.75},
+}
+
+func TestHitRate(t *testing.T) {
+	s := analyzeString(t, ttlLog)
+	for _, tt := range hitRateTests {
+		if r := s.HitRate(tt.ttl); r != tt.want {
+			t.Errorf("HitRate(%v) = %v, want %v", tt.ttl, r, tt.want)
+		}
+	}
+}
+
+func TestSimulateTTL(t *testing.T) {
+	s := analyzeString(t, ttlLog)
+	r := s.SimulateTTL(1000 * time.Second)
+	// a2 expires before its get at 2900, and all three entries
+	// expire before the get of a1 at 4900.
+	want := SimResult{
+		Hits:        1,
+		Misses:      3,
+		Lost:        2,
+		Evictions:   4,
+		Evicted:     3*154 + 5000,
+		BytesServed: 5000,
+		Peak:        2*154 + 5000,
+	}
+	if r != want {
+		t.Errorf("SimulateTTL(1000s) = %+v, want %+v", r, want)
+	}
+}
+
+func TestHitRateEmpty(t *testing.T) {
+	s := analyzeString(t, "1000 get a1\n2000 miss a2\n")
+	if r := s.HitRate(time.Hour); r != 0 {
+		t.Errorf("HitRate with no known entries = %v, want 0", r)
+	}
+}
+
+// lruLog overfills a 10000-byte cache with 4000-byte outputs.
+// Reusing d1 before d3 is put makes d2 the least recently used,
+// so d2 is evicted instead of d1.
+const lruLog = `1000 put a1 d1 4000
+1001 put a2 d2 4000
+1002 get a1
+1003 put a3 d3 4000
+1004 get a1
+1005 get a2
+1006 put a4 d4 4000
+1007 get a3
+1008 get a1
+1009 miss a3
+1010 get a9
+`
+
+func TestSimulateLRU(t *testing.T) {
+	records, err := ParseLog(strings.NewReader(lruLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := SimulateLRU(records, 10000)
+	// The get of a2 at 1005 misses and reinserts d2, evicting d3;
+	// d4 then evicts d1, so the gets at 1007 and 1008 miss too,
+	// each evicting the oldest remaining entry (d2, then d4).
+	want := SimResult{
+		Hits:        2,
+		Misses:      4,
+		Lost:        3,
+		Evictions:   5,
+		Evicted:     20000,
+		BytesServed: 8000,
+		Peak:        8000,
+	}
+	if r != want {
+		t.Errorf("SimulateLRU = %+v, want %+v", r, want)
+	}
+	if rate := r.HitRate(); rate != 2.0/6 {
+		t.Errorf("HitRate() = %v, want %v", rate, 2.0/6)
+	}
+
+	// A cache that holds everything loses no hits.
+	r = SimulateLRU(records, 1<<20)
+	if r.Hits != 5 || r.Lost != 0 || r.Evictions != 0 {
+		t.Errorf("SimulateLRU(1MB) = %+v, want 5 hits and no evictions", r)
+	}
+	// A cache too small for any output caches nothing.
+	r = SimulateLRU(records, 1000)
+	if r.Hits != 0 || r.Lost != 5 || r.Evictions != 0 {
+		t.Errorf("SimulateLRU(1000) = %+v, want 5 lost hits and no evictions", r)
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cachelog
+
+import (
+	"sort"
+	"sync"
+)
+
+// An Entry is a single action or data entry in the cache.
+// All times are Unix times, in seconds.
+type Entry struct {
+	Created    int64  // time of the put that created the entry
+	LastReused int64  // time of the most recent reuse, if Reused
+	Size       int64  // size in bytes
+	Reused     bool   // whether the entry was ever reused
+	Reuses     int    // number of reuses
+	Data       *Entry // for an action entry, its data entry
+}
+
+// DefaultActionSize is the size of an action entry
+// when the log does not record it: the length of
+// "v1 <actionID> <outputID> <size>\n" with 64-digit IDs
+// and a 20-digit size.
+const DefaultActionSize = 154
+
+// An OpCount counts get or miss operations.
+// All counts every operation in the log.
+// Known counts only operations on actions
+// that were put earlier in the log: a miss on an unknown action
+// is usually the first build of that action, and a get on one
+// refers to an entry put before the log began.
+type OpCount struct {
+	All   int
+	Known int
+}
+
+// Stats holds the statistics computed from a sequence of records.
+type Stats struct {
+	Start, End int64 // times of first and last records
+	Lines      int   // number of records
+	Puts       int   // number of put records
+	Skipped    int   // number of records with unrecognized verbs
+	Malformed  int   // number of malformed lines skipped, set by the caller
+	Truncated  bool  // whether the caller stopped reading early, set by the caller
+	Trims      int   // number of trim records
+	Trimmed    int64 // bytes reclaimed by trims, when logged
+	Backward   int   // number of records timestamped before the previous record
+	Hits       OpCount
+	Misses     OpCount
+	Action     CacheStats
+	Data       CacheStats
+
+	// IdleStart and IdleEnd are the times of the consecutive records
+	// separated by the longest gap in the log.
+	IdleStart, IdleEnd int64
+
+	// Days holds the activity for each UTC day, keyed by Day number.
+	// Days with no activity are omitted.
+	Days map[int64]*DayStats
+
+	// Unshared is the total size the data entries would have
+	// if each action entry had its own copy of its data,
+	// instead of sharing identical outputs.
+	Unshared int64
+
+	Actions  map[string]*Entry // action entries, by action ID
+	Outputs  map[string]*Entry // data entries, by output ID
+	Accesses []Access          // puts, gets, and misses of known entries, for simulation
+	Reuses   []Reuse           // reuse events, in log order
+	Ops      []Op              // all put, get, and miss operations
+}
+
+// CacheStats holds the statistics for one kind of cache entry.
+type CacheStats struct {
+	Entries          int     // number of distinct entries
+	Total            int64   // total size of entries
+	Reused           int64   // total size of entries reused at least once
+	NeverReused      int     // number of entries never reused
+	NeverReusedBytes int64   // total size of entries never reused
+	TotalReuses      int     // number of reuses of all entries
+	Reuse            []int64 // sorted entry ages at reuse, in seconds
+	ReuseDelta       []int64 // sorted times since previous reuse, in seconds
+	ReuseCounts      []int   // number of entries by reuse count, bucketed by ReuseCountBuckets
+	Sizes            []int64 // sorted entry sizes
+}
+
+// A DayStats holds the activity for a single UTC calendar day.
+type DayStats struct {
+	Added   int64 // bytes of new data entries
+	Churned int64 // bytes of new data entries never reused
+
+	// ActionReused and DataReused are the bytes of entries
+	// reused for the first time on this day.
+	ActionReused int64
+	DataReused   int64
+
+	Puts    int   // number of put operations
+	Gets    int   // number of get operations
+	Misses  int   // number of miss operations
+	Trims   int   // number of trims
+	Trimmed int64 // bytes reclaimed by trims
+}
+
+// Day returns the UTC day number of the Unix time t.
+func Day(t int64) int64 {
+	return t / 86400
+}
+
+// ReuseCountBuckets lists the upper bounds of the buckets
+// used for CacheStats.ReuseCounts. The last bucket is unbounded.
+var ReuseCountBuckets = []int{0, 1, 5, 20, -1}
+
+// reuseCountBucket returns the index of the bucket for n reuses.
+func reuseCountBucket(n int) int {
+	for i, max := range ReuseCountBuckets {
+		if n <= max {
+			return i
+		}
+	}
+	return len(ReuseCountBuckets) - 1
+}
+
+// A Reuse records the ages and reuse deltas of the action
+// and data entries involved in a single reuse, in seconds,
+// along with their sizes.
+type Reuse struct {
+	ActionAge, ActionDelta int64
+	DataAge, DataDelta     int64
+	ActionSize, DataSize   int64
+}
+
+// An Access is a put, get, or miss of a known action entry.
+type Access struct {
+	Time  int64
+	Verb  Verb
+	Entry *Entry // the action entry
+}
+
+// An Op is a single put, get, or miss operation in the log.
+type Op struct {
+	Time  int64
+	Verb  Verb
+	Added int64 // bytes of new data added by a put
+	Known bool  // for a get or miss, whether the action was put earlier in the log
+}
+
+// Age returns the time spanned by the records, in seconds.
+func (s *Stats) Age() int64 {
+	return s.End - s.Start
+}
+
+// SpanDays returns the number of UTC calendar days spanned
+// by the records, counting the first and last days.
+func (s *Stats) SpanDays() int {
+	if s.Lines == 0 {
+		return 0
+	}
+	return int(Day(s.End)-Day(s.Start)) + 1
+}
+
+// An Analyzer computes statistics for cache log records.
+type Analyzer struct {
+	// ActionSize is the size to assume for action entries
+	// when the log does not record it. Zero means DefaultActionSize.
+	ActionSize int64
+
+	// ClampSkew records negative reuse ages and deltas,
+	// caused by timestamps that go backward, as zero.
+	ClampSkew bool
+}
+
+// Analyze computes statistics for the records,
+// as an Analyzer with default settings would.
+func Analyze(records []Record) *Stats {
+	return new(Analyzer).Analyze(records)
+}
+
+// Analyze computes statistics for the records.
+func (a *Analyzer) Analyze(records []Record) *Stats {
+	actionSize := a.ActionSize
+	if actionSize == 0 {
+		actionSize = DefaultActionSize
+	}
+	s := &Stats{
+		Lines:   len(records),
+		Days:    make(map[int64]*DayStats),
+		Actions: make(map[string]*Entry),
+		Outputs: make(map[string]*Entry),
+	}
+	clamp := func(d int64) int64 {
+		if d < 0 && a.ClampSkew {
+			return 0
+		}
+		return d
+	}
+	for i, rec := range records {
+		t := rec.Time.Unix()
+		if s.Start == 0 {
+			s.Start = t
+		}
+		if i > 0 && t < s.End {
+			s.Backward++
+		}
+		if i > 0 && t-s.End > s.IdleEnd-s.IdleStart {
+			s.IdleStart, s.IdleEnd = s.End, t
+		}
+		s.End = t
+		ds := s.Days[Day(t)]
+		if ds == nil {
+			ds = new(DayStats)
+			s.Days[Day(t)] = ds
+		}
+		switch rec.Verb {
+		case Put, Get, Miss:
+			s.Ops = append(s.Ops, Op{Time: t, Verb: rec.Verb})
+		}
+		switch rec.Verb {
+		case Put:
+			s.Puts++
+			ds.Puts++
+			e1 := s.Outputs[rec.OutputID]
+			if e1 == nil {
+				e1 = &Entry{Created: t, Size: rec.Size}
+				s.Outputs[rec.OutputID] = e1
+				s.Data.Total += rec.Size
+				ds.Added += rec.Size
+				s.Ops[len(s.Ops)-1].Added = rec.Size
+			}
+			e := s.Actions[rec.ActionID]
+			if e == nil {
+				e = &Entry{Created: t, Size: rec.ActionSize, Data: e1}
+				if e.Size == 0 {
+					e.Size = actionSize
+				}
+				s.Actions[rec.ActionID] = e
+				s.Action.Total += e.Size
+				s.Unshared += e1.Size
+			}
+			s.Accesses = append(s.Accesses, Access{t, Put, e})
+
+		case Get, Miss:
+			e := s.Actions[rec.ActionID]
+			if rec.Verb == Get {
+				s.Hits.All++
+				ds.Gets++
+			} else {
+				s.Misses.All++
+				ds.Misses++
+			}
+			if e == nil {
+				continue
+			}
+			s.Ops[len(s.Ops)-1].Known = true
+			if rec.Verb == Get {
+				s.Hits.Known++
+			} else {
+				s.Misses.Known++
+			}
+			if !e.Reused {
+				s.Action.Reused += e.Size
+				ds.ActionReused += e.Size
+				e.LastReused = e.Created
+				e.Reused = true
+			}
+			if !e.Data.Reused {
+				s.Data.Reused += e.Data.Size
+				ds.DataReused += e.Data.Size
+				e.Data.LastReused = e.Data.Created
+				e.Data.Reused = true
+			}
+			s.Reuses = append(s.Reuses, Reuse{
+				ActionAge:   clamp(t - e.Created),
+				ActionDelta: clamp(t - e.LastReused),
+				DataAge:     clamp(t - e.Data.Created),
+				DataDelta:   clamp(t - e.Data.LastReused),
+				ActionSize:  e.Size,
+				DataSize:    e.Data.Size,
+			})
+
+			e.LastReused = t
+			e.Data.LastReused = t
+			e.Reuses++
+			e.Data.Reuses++
+			s.Accesses = append(s.Accesses, Access{t, rec.Verb, e})
+
+		case Trim:
+			s.Trims++
+			s.Trimmed += rec.Size
+			ds.Trims++
+			ds.Trimmed += rec.Size
+
+		default:
+			s.Skipped++
+		}
+	}
+
+	for _, r := range s.Reuses {
+		s.Action.Reuse = append(s.Action.Reuse, r.ActionAge)
+		s.Action.ReuseDelta = append(s.Action.ReuseDelta, r.ActionDelta)
+		s.Data.Reuse = append(s.Data.Reuse, r.DataAge)
+		s.Data.ReuseDelta = append(s.Data.ReuseDelta, r.DataDelta)
+	}
+	sortAll(s.Action.Reuse, s.Action.ReuseDelta, s.Data.Reuse, s.Data.ReuseDelta)
+	s.Action.finish(s.Actions)
+	s.Data.finish(s.Outputs)
+	for _, e := range s.Outputs {
+		if !e.Reused {
+			s.Days[Day(e.Created)].Churned += e.Size
+		}
+	}
+	return s
+}
+
+// finish counts the entries by number of reuses
+// and collects their sizes. The caller sorts the reuse lists.
+func (c *CacheStats) finish(entries map[string]*Entry) {
+	c.ReuseCounts = make([]int, len(ReuseCountBuckets))
+	for _, e := range entries {
+		c.Entries++
+		c.Sizes = append(c.Sizes, e.Size)
+		if !e.Reused {
+			c.NeverReused++
+			c.NeverReusedBytes += e.Size
+		}
+		c.ReuseCounts[reuseCountBucket(e.Reuses)]++
+		c.TotalReuses += e.Reuses
+	}
+	sortInt64s(c.Sizes)
+}
+
+// MeanReuses returns the mean number of reuses of the entries
+// reused at least once, or 0 if none were.
+func (c *CacheStats) MeanReuses() float64 {
+	n := c.Entries - c.NeverReused
+	if n == 0 {
+		return 0
+	}
+	return float64(c.TotalReuses) / float64(n)
+}
+
+// sortAll sorts the lists concurrently.
+func sortAll(lists ...[]int64) {
+	var wg sync.WaitGroup
+	for _, x := range lists {
+		wg.Add(1)
+		go func(x []int64) {
+			sortInt64s(x)
+			wg.Done()
+		}(x)
+	}
+	wg.Wait()
+}
+
+// sortInt64s sorts x in increasing order.
+func sortInt64s(x []int64) {
+	sort.Slice(x, func(i, j int) bool { return x[i] < x[j] })
+}
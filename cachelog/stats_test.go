@@ -0,0 +1,263 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cachelog
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func analyzeString(t *testing.T, log string) *Stats {
+	t.Helper()
+	p := &Parser{Name: "test"}
+	records, err := p.Parse(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Analyze(records)
+}
+
+// A logGen generates a synthetic log of n put/get pairs.
+// Each put creates a new action with a new 1000-byte output,
+// and the following get reuses it one second later.
+type logGen struct {
+	n   int
+	i   int
+	buf []byte
+}
+
+func (g *logGen) Read(b []byte) (int, error) {
+	for len(g.buf) == 0 {
+		if g.i >= g.n {
+			return 0, io.EOF
+		}
+		t := 1500000000 + 2*g.i
+		g.buf = []byte(fmt.Sprintf("%d put %064x %064x 1000\n%d get %064x\n", t, g.i, g.i, t+1, g.i))
+		g.i++
+	}
+	n := copy(b, g.buf)
+	g.buf = g.buf[n:]
+	return n, nil
+}
+
+func TestAnalyzeLarge(t *testing.T) {
+	const n = 100000
+	p := &Parser{Name: "large"}
+	records, err := p.Parse(&logGen{n: n})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2*n {
+		t.Fatalf("got %d records, want %d", len(records), 2*n)
+	}
+	s := Analyze(records)
+	if s.Puts != n || s.Hits.All != n {
+		t.Errorf("got %d puts, %d hits, want %d, %d", s.Puts, s.Hits.All, n, n)
+	}
+	if s.Data.Total != 1000*n || s.Data.Reused != 1000*n {
+		t.Errorf("data total %d, reused %d, want %d, %d", s.Data.Total, s.Data.Reused, 1000*n, 1000*n)
+	}
+	if s.Action.Total != 154*n {
+		t.Errorf("action total %d, want %d", s.Action.Total, 154*n)
+	}
+	if s.Age() != 2*n-1 {
+		t.Errorf("age %d, want %d", s.Age(), 2*n-1)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	s := analyzeString(t, testLog)
+	if s.Age() != 4000 {
+		t.Errorf("Age() = %d, want 4000", s.Age())
+	}
+	if s.Lines != 7 || s.Puts != 3 || s.Skipped != 0 {
+		t.Errorf("Lines, Puts, Skipped = %d, %d, %d, want 7, 3, 0", s.Lines, s.Puts, s.Skipped)
+	}
+	if want := (OpCount{All: 3, Known: 3}); s.Hits != want {
+		t.Errorf("Hits = %+v, want %+v", s.Hits, want)
+	}
+	if want := (OpCount{All: 1, Known: 0}); s.Misses != want {
+		t.Errorf("Misses = %+v, want %+v", s.Misses, want)
+	}
+
+	if s.Unshared != 13000 {
+		t.Errorf("Unshared = %d, want 13000", s.Unshared)
+	}
+
+	wantAction := CacheStats{
+		Entries:          3,
+		Total:            3 * 154,
+		Reused:           2 * 154,
+		NeverReused:      1,
+		NeverReusedBytes: 154,
+		TotalReuses:      3,
+		Reuse:            []int64{1000, 2900, 4000},
+		ReuseDelta:       []int64{1000, 2900, 3000},
+		ReuseCounts:      []int{1, 1, 1, 0, 0},
+		Sizes:            []int64{154, 154, 154},
+	}
+	if !reflect.DeepEqual(s.Action, wantAction) {
+		t.Errorf("Action = %+v, want %+v", s.Action, wantAction)
+	}
+	wantData := CacheStats{
+		Entries:     2,
+		Total:       8000,
+		Reused:      8000,
+		TotalReuses: 3,
+		Reuse:       []int64{1000, 2900, 4000},
+		ReuseDelta:  []int64{1000, 2900, 3000},
+		ReuseCounts: []int{0, 1, 1, 0, 0},
+		Sizes:       []int64{3000, 5000},
+	}
+	if !reflect.DeepEqual(s.Data, wantData) {
+		t.Errorf("Data = %+v, want %+v", s.Data, wantData)
+	}
+}
+
+func TestAnalyzeActionSize(t *testing.T) {
+	s := analyzeString(t, "1000 put a1 d1 5000 200\n1100 put a2 d2 3000\n1200 get a1\n")
+	if s.Action.Total != 200+154 || s.Action.Reused != 200 {
+		t.Errorf("action total, reused = %d, %d, want %d, %d", s.Action.Total, s.Action.Reused, 200+154, 200)
+	}
+
+	records, err := ParseLog(strings.NewReader("1000 put a1 d1 5000 200\n1100 put a2 d2 3000\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Analyzer{ActionSize: 300}
+	if s := a.Analyze(records); s.Action.Total != 200+300 {
+		t.Errorf("Analyzer{ActionSize: 300}: action total = %d, want %d", s.Action.Total, 200+300)
+	}
+}
+
+func TestAnalyzeLargeDelta(t *testing.T) {
+	// 3e9 seconds (about 95 years) overflows a 32-bit int.
+	s := analyzeString(t, "1000 put a1 d1 5000\n3000001000 get a1\n6000001000 get a1\n")
+	want := []int64{3000000000, 6000000000}
+	if !reflect.DeepEqual(s.Data.Reuse, want) {
+		t.Errorf("Data.Reuse = %v, want %v", s.Data.Reuse, want)
+	}
+	if want := []int64{3000000000, 3000000000}; !reflect.DeepEqual(s.Data.ReuseDelta, want) {
+		t.Errorf("Data.ReuseDelta = %v, want %v", s.Data.ReuseDelta, want)
+	}
+}
+
+func TestAnalyzeIdleGap(t *testing.T) {
+	s := analyzeString(t, "1000 put a1 d1 5000\n1400 get a1\n5000 get a1\n5100 miss a2\n")
+	if s.IdleStart != 1400 || s.IdleEnd != 5000 {
+		t.Errorf("idle gap = %d to %d, want 1400 to 5000", s.IdleStart, s.IdleEnd)
+	}
+}
+
+func TestAnalyzeActiveDays(t *testing.T) {
+	// Activity on days 0, 1, and 4 of a five-day span.
+	s := analyzeString(t, "1000 put a1 d1 5000\n87000 get a1\n346000 get a1\n")
+	if len(s.Days) != 3 || s.SpanDays() != 5 {
+		t.Errorf("active %d of %d days, want 3 of 5", len(s.Days), s.SpanDays())
+	}
+	if n := analyzeString(t, "").SpanDays(); n != 0 {
+		t.Errorf("SpanDays() = %d for empty log, want 0", n)
+	}
+}
+
+func TestMeanReuses(t *testing.T) {
+	// a1 is reused three times and a2 once; a3 is never reused.
+	// The data entry d1 is shared by a1 and a2.
+	s := analyzeString(t, "1000 put a1 d1 5000\n1000 put a2 d1 5000\n1000 put a3 d3 100\n"+
+		"2000 get a1\n3000 get a1\n4000 miss a1\n5000 get a2\n")
+	if s.Action.TotalReuses != 4 || s.Action.MeanReuses() != 2 {
+		t.Errorf("action: %d reuses, mean %v, want 4, 2", s.Action.TotalReuses, s.Action.MeanReuses())
+	}
+	if s.Data.TotalReuses != 4 || s.Data.MeanReuses() != 4 {
+		t.Errorf("data: %d reuses, mean %v, want 4, 4", s.Data.TotalReuses, s.Data.MeanReuses())
+	}
+	if n := analyzeString(t, "1000 put a1 d1 5000\n").Data.MeanReuses(); n != 0 {
+		t.Errorf("MeanReuses with no reuse = %v, want 0", n)
+	}
+}
+
+func TestAnalyzeClockSkew(t *testing.T) {
+	// The clock steps back 500 seconds before the second get.
+	const log = "1000 put a1 d1 5000\n1400 get a1\n900 get a1\n1600 get a1\n"
+	s := analyzeString(t, log)
+	if s.Backward != 1 {
+		t.Errorf("Backward = %d, want 1", s.Backward)
+	}
+	if want := []int64{-500, 400, 700}; !reflect.DeepEqual(s.Data.ReuseDelta, want) {
+		t.Errorf("Data.ReuseDelta = %v, want %v", s.Data.ReuseDelta, want)
+	}
+
+	records, err := ParseLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = (&Analyzer{ClampSkew: true}).Analyze(records)
+	if s.Backward != 1 {
+		t.Errorf("ClampSkew: Backward = %d, want 1", s.Backward)
+	}
+	if want := []int64{0, 400, 700}; !reflect.DeepEqual(s.Data.ReuseDelta, want) {
+		t.Errorf("ClampSkew: Data.ReuseDelta = %v, want %v", s.Data.ReuseDelta, want)
+	}
+	if want := []int64{0, 400, 600}; !reflect.DeepEqual(s.Data.Reuse, want) {
+		t.Errorf("ClampSkew: Data.Reuse = %v, want %v", s.Data.Reuse, want)
+	}
+}
+
+func benchmarkLists(n int) [][]int64 {
+	lists := make([][]int64, 4)
+	x := uint32(1)
+	for i := range lists {
+		lists[i] = make([]int64, n)
+		for j := range lists[i] {
+			x = x*1664525 + 1013904223
+			lists[i][j] = int64(x >> 8)
+		}
+	}
+	return lists
+}
+
+func BenchmarkSortReuses(b *testing.B) {
+	const n = 1000000
+	orig := benchmarkLists(n)
+	lists := benchmarkLists(n)
+	reset := func() {
+		for i := range lists {
+			copy(lists[i], orig[i])
+		}
+	}
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			reset()
+			b.StartTimer()
+			for _, x := range lists {
+				sortInt64s(x)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			reset()
+			b.StartTimer()
+			sortAll(lists...)
+		}
+	})
+}
+
+func BenchmarkAnalyze(b *testing.B) {
+	p := &Parser{Name: "large"}
+	records, err := p.Parse(&logGen{n: 100000})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Analyze(records)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// A cdfPoint is a point on a cumulative distribution:
+// the fraction of samples at or below time t (in seconds).
+type cdfPoint struct {
+	t        int64
+	fraction float64
+}
+
+// reuseCDF returns the cumulative distribution of the sorted list x
+// at n times evenly spaced from max/n up to the max of x.
+func reuseCDF(x []int64, n int) []cdfPoint {
+	if len(x) == 0 || n <= 0 {
+		return nil
+	}
+	max := x[len(x)-1]
+	var points []cdfPoint
+	j := 0
+	for i := 1; i <= n; i++ {
+		t := max * int64(i) / int64(n)
+		for j < len(x) && x[j] <= t {
+			j++
+		}
+		points = append(points, cdfPoint{t, float64(j) / float64(len(x))})
+	}
+	return points
+}
+
+// printCDF prints the reuse time CDFs of the action and data caches
+// at n points each.
+func printCDF(w io.Writer, s *Stats, n int) {
+	for _, c := range []struct {
+		name string
+		x    []int64
+	}{
+		{"action", s.Action.Reuse},
+		{"data", s.Data.Reuse},
+	} {
+		fmt.Fprintf(w, "%s reuse time cdf\n", c.name)
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "\ttime (%s)\tfraction\t\n", *unit)
+		for _, p := range reuseCDF(c.x, n) {
+			fmt.Fprintf(tw, "\t%.2f\t%.4f\t\n", float64(p.t)/unitSize, p.fraction)
+		}
+		tw.Flush()
+	}
+}
+
+// printCDFCSV prints the reuse time CDFs as CSV,
+// one row per point, with times in seconds.
+func printCDFCSV(w io.Writer, s *Stats, n int) {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"kind", "time", "fraction"})
+	for _, p := range reuseCDF(s.Action.Reuse, n) {
+		cw.Write([]string{"action", strconv.FormatInt(p.t, 10), strconv.FormatFloat(p.fraction, 'f', 4, 64)})
+	}
+	for _, p := range reuseCDF(s.Data.Reuse, n) {
+		cw.Write([]string{"data", strconv.FormatInt(p.t, 10), strconv.FormatFloat(p.fraction, 'f', 4, 64)})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		fatal(err)
+	}
+}
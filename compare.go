@@ -0,0 +1,106 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+// printCompare prints the statistics in s1 and s2, read from
+// the logs named name1 and name2, side by side, along with
+// the change from s1 to s2.
+func printCompare(w io.Writer, name1 string, s1 *Stats, name2 string, s2 *Stats) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	label1, label2 := compareLabels(name1, name2)
+	fmt.Fprintf(tw, "\t\t%s\t%s\tdelta\t\n", label1, label2)
+	bytes := func(name string, x1, x2 int64) {
+		delta := bytesCell(x2 - x1)
+		if x2 >= x1 {
+			delta = "+" + delta
+		}
+		fmt.Fprintf(tw, "\t%s\t%s\t%s\t%s\t\n", name, bytesCell(x1), bytesCell(x2), delta)
+	}
+	times := func(name string, x1, x2 float64) {
+		fmt.Fprintf(tw, "\t%s (%s)\t%.2f\t%.2f\t%+.2f\t\n", name, *unit, x1/unitSize, x2/unitSize, (x2-x1)/unitSize)
+	}
+	rate := func(name string, hits1, misses1, hits2, misses2 int) {
+		delta := "n/a"
+		if hits1+misses1 > 0 && hits2+misses2 > 0 {
+			r1 := 100 * float64(hits1) / float64(hits1+misses1)
+			r2 := 100 * float64(hits2) / float64(hits2+misses2)
+			delta = fmt.Sprintf("%+.1f%%", r2-r1)
+		}
+		fmt.Fprintf(tw, "\t%s\t%s\t%s\t%s\t\n", name, hitRate(hits1, misses1), hitRate(hits2, misses2), delta)
+	}
+	percentiles := func(name string, x1, x2 []int64) {
+		if len(x1) == 0 || len(x2) == 0 {
+			return
+		}
+		times(name+" 50%", float64(percentile(x1, 50, 100)), float64(percentile(x2, 50, 100)))
+		times(name+" 90%", float64(percentile(x1, 90, 100)), float64(percentile(x2, 90, 100)))
+		times(name+" 99%", float64(percentile(x1, 99, 100)), float64(percentile(x2, 99, 100)))
+	}
+
+	times("cache age", float64(s1.Age()), float64(s2.Age()))
+	rate("hit rate", s1.Hits.All, s1.Misses.All, s2.Hits.All, s2.Misses.All)
+	for _, c := range []struct {
+		name   string
+		c1, c2 *cachelog.CacheStats
+	}{
+		{"action", &s1.Action, &s2.Action},
+		{"data", &s1.Data, &s2.Data},
+	} {
+		bytes(c.name+" bytes", c.c1.Total, c.c2.Total)
+		bytes(c.name+" reused", c.c1.Reused, c.c2.Reused)
+		percentiles(c.name+" reuse time", c.c1.Reuse, c.c2.Reuse)
+		percentiles(c.name+" reuse delta", c.c1.ReuseDelta, c.c2.ReuseDelta)
+	}
+	tw.Flush()
+}
+
+// compareLabels returns column labels for the logs named name1 and name2,
+// each a comma-separated list of file names. It shortens each file name
+// to its last few path elements, using the fewest that tell all the
+// names apart, or returns the names unchanged if no shortening does.
+func compareLabels(name1, name2 string) (string, string) {
+	for n := 1; ; n++ {
+		label1, ok1 := pathSuffixes(name1, n)
+		label2, ok2 := pathSuffixes(name2, n)
+		if ok1 && ok2 && label1 != label2 {
+			return label1, label2
+		}
+		if label1 == name1 && label2 == name2 {
+			return name1, name2
+		}
+	}
+}
+
+// pathSuffixes returns the comma-separated list of file names,
+// each shortened to its last n path elements,
+// and whether the shortened names are all different.
+func pathSuffixes(list string, n int) (string, bool) {
+	names := strings.Split(list, ",")
+	sep := string(filepath.Separator)
+	seen := make(map[string]bool)
+	ok := true
+	for i, name := range names {
+		elem := strings.Split(name, sep)
+		if len(elem) > n {
+			elem = elem[len(elem)-n:]
+		}
+		names[i] = strings.Join(elem, sep)
+		if seen[names[i]] {
+			ok = false
+		}
+		seen[names[i]] = true
+	}
+	return strings.Join(names, ","), ok
+}
@@ -0,0 +1,48 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+// printCSV prints one row per reuse event in s
+// for each of the action and data caches.
+func printCSV(w io.Writer, s *Stats) {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"kind", "age", "delta"})
+	for _, r := range s.Reuses {
+		cw.Write([]string{"action", strconv.FormatInt(r.ActionAge, 10), strconv.FormatInt(r.ActionDelta, 10)})
+	}
+	for _, r := range s.Reuses {
+		cw.Write([]string{"data", strconv.FormatInt(r.DataAge, 10), strconv.FormatInt(r.DataDelta, 10)})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		fatal(err)
+	}
+}
+
+// printCumulativeCSV prints one row per day spanned by the log,
+// giving the cumulative action and data bytes reused by the end of that day.
+func printCumulativeCSV(w io.Writer, s *Stats) {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"day", "action", "data"})
+	first := cachelog.Day(s.Start)
+	var a, d int64
+	for i, ds := range timelineDays(s) {
+		a += ds.ActionReused
+		d += ds.DataReused
+		cw.Write([]string{dayLabel(first + int64(i)), strconv.FormatInt(a, 10), strconv.FormatInt(d, 10)})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		fatal(err)
+	}
+}
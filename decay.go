@@ -0,0 +1,49 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// printDecay prints, for each of the bounds (in seconds),
+// the probability that a data entry left unused for that long
+// is used again. Each access to an entry starts an idle gap,
+// which ends either at the next reuse or, censored, at the end of the log.
+// A gap counts toward bound d if it lasted at least d,
+// and counts as reused if it ended in a reuse.
+func printDecay(w io.Writer, s *Stats, bounds []int64) {
+	gaps := s.Data.ReuseDelta
+	var censored []int64
+	for _, e := range s.Outputs {
+		last := e.Created
+		if e.Reused {
+			last = e.LastReused
+		}
+		censored = append(censored, s.End-last)
+	}
+
+	fmt.Fprintf(w, "reuse probability by idle time (data)\n")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\tidle\tgaps\treused\t\n")
+	for _, b := range append([]int64{0}, bounds...) {
+		var n, reused int64
+		for _, g := range gaps {
+			if g >= b {
+				n++
+				reused++
+			}
+		}
+		for _, g := range censored {
+			if g >= b {
+				n++
+			}
+		}
+		fmt.Fprintf(tw, "\t>= %s\t%d\t%s\t\n", durationLabel(b), n, percent(reused, n))
+	}
+	tw.Flush()
+}
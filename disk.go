@@ -0,0 +1,61 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// printDiskCheck prints how many of the logged data entries
+// are missing from the cache directory dir, presumably trimmed
+// since they were logged. Entry files are stored in subdirectories
+// named by the first two hex digits of their IDs.
+func printDiskCheck(w io.Writer, dir string, s *Stats) {
+	var missing int
+	var missingBytes int64
+	for id, e := range s.Outputs {
+		if len(id) < 2 {
+			continue
+		}
+		_, err := os.Stat(filepath.Join(dir, id[:2], id+"-d"))
+		if os.IsNotExist(err) {
+			missing++
+			missingBytes += e.Size
+		} else if err != nil {
+			fatal(err)
+		}
+	}
+	fmt.Fprintf(w, "on disk: %d of %d data objects missing, %s\n", missing, s.Data.Entries, bytesLabel(missingBytes))
+}
+
+// printDiskUsage prints the total size of the files in the
+// cache directory dir, alongside the logged totals.
+// The cache's own log and trim files are not counted.
+func printDiskUsage(w io.Writer, dir string, s *Stats) {
+	var files int
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() || name == "trim.txt" || strings.HasPrefix(name, "log.txt") {
+			return nil
+		}
+		files++
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Fprintf(w, "disk usage: %s in %d files\n", bytesLabel(size), files)
+	fmt.Fprintf(w, "\tlogged: %s (%s action, %s data), %s never reused\n",
+		bytesLabel(s.Action.Total+s.Data.Total), bytesCell(s.Action.Total), bytesCell(s.Data.Total), bytesCell(s.Action.NeverReusedBytes+s.Data.NeverReusedBytes))
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+// A jsonRecord is the -dump=ndjson form of a cachelog.Record.
+type jsonRecord struct {
+	Time       int64 // Unix time, in seconds
+	Verb       string
+	ActionID   string
+	OutputID   string `json:",omitempty"`
+	Size       int64  `json:",omitempty"`
+	ActionSize int64  `json:",omitempty"`
+	Source     string `json:",omitempty"` // only when reading several logs
+}
+
+// dumpRecords prints the records in the named logs as
+// newline-delimited JSON, one object per record.
+// It streams the records as it parses them, so it does not
+// hold the logs in memory, and it ignores -since and -until.
+func dumpRecords(w io.Writer, files []string) {
+	enc := json.NewEncoder(w)
+	for _, file := range files {
+		_, err := scanRecords(file, func(rec cachelog.Record) {
+			jr := jsonRecord{
+				Time:       rec.Time.Unix(),
+				Verb:       rec.Verb.String(),
+				ActionID:   rec.ActionID,
+				OutputID:   rec.OutputID,
+				Size:       rec.Size,
+				ActionSize: rec.ActionSize,
+			}
+			if len(files) > 1 {
+				jr.Source = rec.Source
+			}
+			if err := enc.Encode(&jr); err != nil {
+				fatal(err)
+			}
+		})
+		if err != nil {
+			fatal(err)
+		}
+	}
+}
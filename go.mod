@@ -0,0 +1,3 @@
+module rsc.io/gocachelogstat
+
+go 1.21
@@ -0,0 +1,152 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+// histWidth is the width of the longest bar in a histogram.
+const histWidth = 60
+
+// printHist prints a horizontal bar chart with one bar per label.
+func printHist(w io.Writer, labels []string, counts []int64) {
+	printBars(w, labels, counts, func(c int64) string { return fmt.Sprint(c) })
+}
+
+// printBytesHist is like printHist for counts of bytes,
+// which it formats using bytesCell.
+func printBytesHist(w io.Writer, labels []string, counts []int64) {
+	printBars(w, labels, counts, bytesCell)
+}
+
+// printBars prints the bar chart for printHist,
+// formatting each count using format.
+func printBars(w io.Writer, labels []string, counts []int64, format func(int64) string) {
+	var max int64
+	width, cwidth := 0, 8
+	for i, c := range counts {
+		if max < c {
+			max = c
+		}
+		if width < len(labels[i]) {
+			width = len(labels[i])
+		}
+		if n := len(format(c)); cwidth < n {
+			cwidth = n
+		}
+	}
+	for i, c := range counts {
+		bar := ""
+		if c > 0 {
+			bar = " " + strings.Repeat("#", int((c*histWidth+max-1)/max))
+		}
+		fmt.Fprintf(w, "\t%*s %*s%s\n", width, labels[i], cwidth, format(c), bar)
+	}
+}
+
+// printSizeHist prints a histogram of data object sizes,
+// using buckets that grow by a factor of 4 starting at 1KB.
+func printSizeHist(w io.Writer, outputs map[string]*cachelog.Entry) {
+	var labels []string
+	var counts []int64
+	var sizes []int64
+	for _, e := range outputs {
+		sizes = append(sizes, e.Size)
+	}
+	lo := int64(0)
+	for hi := int64(1024); len(sizes) > 0; hi *= 4 {
+		var n int64
+		rest := sizes[:0]
+		for _, size := range sizes {
+			if size < hi {
+				n++
+			} else {
+				rest = append(rest, size)
+			}
+		}
+		sizes = rest
+		labels = append(labels, bytesCell(lo)+"-"+bytesCell(hi))
+		counts = append(counts, n)
+		lo = hi
+	}
+	fmt.Fprintf(w, "data object sizes\n")
+	printHist(w, labels, counts)
+}
+
+// printReuseHist prints a histogram of the reuse times in the list x,
+// using the bucket boundaries (in seconds) in bounds.
+func printReuseHist(w io.Writer, name string, x []int64, bounds []int64) {
+	labels := make([]string, len(bounds)+1)
+	counts := make([]int64, len(bounds)+1)
+	for i := range labels {
+		switch {
+		case i == 0:
+			labels[i] = "<" + durationLabel(bounds[0])
+		case i == len(bounds):
+			labels[i] = ">" + durationLabel(bounds[i-1])
+		default:
+			labels[i] = durationLabel(bounds[i-1]) + "-" + durationLabel(bounds[i])
+		}
+	}
+	for _, v := range x {
+		i := sort.Search(len(bounds), func(i int) bool { return v < bounds[i] })
+		counts[i]++
+	}
+	fmt.Fprintf(w, "%s reuse times\n", name)
+	printHist(w, labels, counts)
+}
+
+// durationLabel returns a short label for the duration of sec seconds.
+func durationLabel(sec int64) string {
+	switch {
+	case sec%86400 == 0:
+		return fmt.Sprintf("%dd", sec/86400)
+	case sec%3600 == 0:
+		return fmt.Sprintf("%dh", sec/3600)
+	case sec%60 == 0:
+		return fmt.Sprintf("%dm", sec/60)
+	}
+	return fmt.Sprintf("%ds", sec)
+}
+
+// bytesLabel returns the label for n bytes in the text report:
+// "n bytes" by default, or a scaled size like "7.6 GiB" with -human
+// (or "8.1 GB" with -si).
+func bytesLabel(n int64) string {
+	if !*human && !*si {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	return bytesCell(n)
+}
+
+// bytesCell is like bytesLabel but omits the word "bytes"
+// from the default label, for use in tables and after a total.
+func bytesCell(n int64) string {
+	if !*human && !*si {
+		return fmt.Sprint(n)
+	}
+	base, units := 1024.0, []string{"KiB", "MiB", "GiB", "TiB", "PiB"}
+	if *si {
+		base, units = 1000, []string{"KB", "MB", "GB", "TB", "PB"}
+	}
+	f := float64(n)
+	if math.Abs(f) < base {
+		return fmt.Sprintf("%d B", n)
+	}
+	for i, u := range units {
+		f /= base
+		if math.Abs(f) < base || i == len(units)-1 {
+			return fmt.Sprintf("%.1f %s", f, u)
+		}
+	}
+	panic("unreachable")
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+// jsonStats is the output printed by -json.
+type jsonStats struct {
+	Unit      string  // unit for all times
+	Age       float64 // cache age
+	Hits      int     // number of get operations
+	Misses    int     // number of miss operations
+	Malformed int     `json:",omitempty"` // number of malformed lines skipped (-lenient)
+	Truncated bool    `json:",omitempty"` // whether reading stopped at -maxlines
+	Backward  int     `json:",omitempty"` // number of timestamps that went backward
+	Action    jsonCache
+	Data      jsonCache
+}
+
+// jsonCache holds the statistics for a single cache kind.
+// Reuse and ReuseDelta map percentile labels (p10, p95, p999, max, ...)
+// to times in the -unit. They are omitted when there was no reuse.
+type jsonCache struct {
+	Total            int64
+	Reused           int64
+	Entries          int                // number of distinct entries
+	NeverReused      int                // number of entries never reused
+	NeverReusedBytes int64              // total size of entries never reused
+	MeanReuses       float64            // mean reuses of entries reused at least once
+	Reuse            map[string]float64 `json:",omitempty"`
+	ReuseDelta       map[string]float64 `json:",omitempty"`
+}
+
+// printJSON prints st as a single line of JSON,
+// or indented by two spaces if -pretty is set.
+func printJSON(w io.Writer, st *jsonStats) {
+	var js []byte
+	var err error
+	if *pretty {
+		js, err = json.MarshalIndent(st, "", "  ")
+	} else {
+		js, err = json.Marshal(st)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	w.Write(append(js, '\n'))
+}
+
+func newJSONStats(s *Stats) *jsonStats {
+	return &jsonStats{
+		Unit:      *unit,
+		Age:       float64(s.Age()) / unitSize,
+		Hits:      s.Hits.All,
+		Misses:    s.Misses.All,
+		Malformed: s.Malformed,
+		Truncated: s.Truncated,
+		Backward:  s.Backward,
+		Action:    newJSONCache(&s.Action),
+		Data:      newJSONCache(&s.Data),
+	}
+}
+
+func newJSONCache(c *cachelog.CacheStats) jsonCache {
+	return jsonCache{
+		Total:            c.Total,
+		Entries:          c.Entries,
+		Reused:           c.Reused,
+		NeverReused:      c.NeverReused,
+		NeverReusedBytes: c.NeverReusedBytes,
+		MeanReuses:       c.MeanReuses(),
+		Reuse:            percentileMap(c.Reuse),
+		ReuseDelta:       percentileMap(c.ReuseDelta),
+	}
+}
+
+// percentileMap returns the percentiles of the sorted list x, in the -unit.
+func percentileMap(x []int64) map[string]float64 {
+	q := computeQuantiles(x, exportPctiles)
+	if q == nil {
+		return nil
+	}
+	m := make(map[string]float64)
+	for _, v := range q.pctiles {
+		m["p"+strings.Replace(v.p.label, ".", "", -1)] = float64(v.value) / unitSize
+	}
+	m["max"] = float64(q.max) / unitSize
+	return m
+}
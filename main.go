@@ -7,156 +7,1197 @@
 //
 // Please run:
 //
-//	go get -u rsc.io/gocachelogstat
+//	go install rsc.io/gocachelogstat@latest
 //	gocachelogstat
 //
+// By default gocachelogstat reads log.txt from the directory
+// reported by "go env GOCACHE". The -cachedir flag overrides that,
+// which is useful when go is not on $PATH or when inspecting
+// a cache copied from another machine. The -logfile flag names a log
+// file to read directly, with "-" meaning standard input.
+// A gzip-compressed log is decompressed automatically.
+// The -rotated flag adds the rotated logs log.txt.1, log.txt.2, and so on,
+// reading them oldest first as one continuous log. An entry put in an
+// earlier file and put again in a later one is counted only once.
+//
+// The -cachedir and -logfile flags may be repeated, and may be combined,
+// to merge several logs into one report. The merged records are analyzed
+// in time order, and an entry put in more than one log is counted only once.
+// The -by-source flag adds a table of the statistics for each log on its own.
+//
+// The -json flag prints the statistics as a single JSON object
+// instead of the text report, on one line unless -pretty is also given.
+// The -csv flag prints the raw reuse events instead, one row per event,
+// giving the cache kind, the age of the entry at reuse, and the time
+// since its previous reuse, in seconds.
+// The -prom flag prints the statistics in the Prometheus text format;
+// see printProm for the metric names. The -summary flag prints
+// a single line instead; see printSummary for its format.
+// The -dump=ndjson flag skips the analysis and prints the parsed
+// log records instead, one JSON object per line.
+//
+// The -watch flag keeps gocachelogstat running, rereading the log
+// and reprinting the statistics periodically. It reads only the lines
+// added since the previous update, rereading the whole log only if
+// the log has shrunk, as happens when it is rotated.
+//
+// The -listen flag runs an HTTP server instead, serving the Prometheus
+// metrics at /metrics and the JSON statistics at /stats.json.
+// The server rereads the log on each request or, if -interval is set,
+// periodically. Since it reopens the log each time, it follows
+// the log across rotation.
+//
+// The -since and -until flags restrict the statistics to log lines
+// in a time window. Each takes either an RFC3339 time or a duration,
+// which is interpreted relative to the last event in the log:
+// -since=720h (or -since=30d) means the last 30 days of the log.
+// Lines outside the window are ignored entirely, so a cache entry
+// put before the window is unknown and its reuses inside the window
+// are not counted.
+//
+// The -score flag prints a single cache efficiency score from 0 to 100,
+// the weighted mean of the hit rate, the fraction of data bytes reused,
+// and the fraction of data objects reused at least once. The weights
+// default to 50, 30, and 20 and can be changed with -score-weights.
+//
+// The -min-size and -max-size flags exclude data objects outside a range
+// of sizes from all statistics. The puts of an excluded object are dropped,
+// along with the gets and misses of the actions that last produced it,
+// so those actions are excluded too. The report counts what was excluded.
+//
+// The text report gives byte counts exactly by default. The -human flag
+// prints them in binary units instead, like 7.6 GiB, and the -si flag
+// in decimal units, like 8.1 GB. Neither affects -json, -csv, or -prom.
+//
+// Go 1.10 logs do not record the size of action entries, so the action
+// cache totals use an estimate of 154 bytes per entry. The -action-size
+// flag replaces that estimate, perhaps with a size measured from the
+// entries in a real cache. It affects only the action cache statistics;
+// data cache totals always come from the sizes in the log.
+//
+// The -tail flag takes a duration and keeps only the events within that
+// long of the last event: -tail=7d means the last week of the log.
+// Combined with -since and -until, the window is the intersection of the
+// two, so -tail narrows -since but never widens it, and a duration passed
+// to -until counts back from the same last event as -tail.
+//
+// Percentiles use the nearest-rank method by default: the p'th percentile
+// of n samples is the sample at index n*p/100, so it is always an observed
+// value. The -quantile-method=linear flag interpolates between the two
+// nearest samples instead, which matches most spreadsheets and monitoring tools.
 package main
 
 import (
-	"bytes"
+	"bufio"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
+	"math"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+var (
+	bySource         = flag.Bool("by-source", false, "with several logs, also print statistics for each log")
+	jsonFlag         = flag.Bool("json", false, "print statistics as JSON")
+	compareFile      = flag.String("compare", "", "compare the statistics with those for the log in `file`")
+	csvFlag          = flag.Bool("csv", false, "print reuse events as CSV")
+	promFlag         = flag.Bool("prom", false, "print statistics in Prometheus text format")
+	summary          = flag.Bool("summary", false, "print a one-line summary of the statistics")
+	format           = flag.String("format", "text", "print statistics in `format` (text, markdown)")
+	templateFlag     = flag.String("template", "", "print statistics using the Go `template` (or @file; see below)")
+	unit             = flag.String("unit", "days", "report times in `unit` (seconds, minutes, hours, or days)")
+	quantileMethod   = flag.String("quantile-method", "nearest", "compute percentiles by `method` (nearest or linear)")
+	quiet            = flag.Bool("quiet", false, "omit the golang.org/issue/22990 banner and markdown fences")
+	listen           = flag.String("listen", "", "serve statistics over HTTP on `addr` instead of printing them")
+	interval         = flag.Duration("interval", 0, "with -listen, reread the log every `duration` instead of on each request")
+	watch            = flag.Duration("watch", 0, "reread the log and reprint the statistics every `duration`")
+	score            = flag.Bool("score", false, "print a cache efficiency score from 0 to 100")
+	scoreWeightsFlag = flag.String("score-weights", "50,30,20", "weight the efficiency score components (hit rate, reuse, no waste) by `weights`")
+	si               = flag.Bool("si", false, "print byte counts in decimal units (KB, MB, GB)")
+	sizeReuse        = flag.Bool("size-reuse", false, "print the fraction of data objects reused by object size")
+	since            = flag.String("since", "", "ignore log lines before `time` (RFC3339, or duration before last event)")
+	hist             = flag.String("hist", "", "print histograms of `kinds` (comma-separated: size, reuse)")
+	cdf              = flag.Int("cdf", 0, "print the reuse time CDF at `n` evenly spaced times (as CSV with -csv)")
+	checkDisk        = flag.Bool("check-disk", false, "report logged data objects missing from the cache directory")
+	decay            = flag.Bool("decay", false, "print the probability of reuse by idle time, using the -buckets boundaries")
+	dump             = flag.String("dump", "", "print the parsed log records in `format` (ndjson) instead of statistics")
+	diskUsage        = flag.Bool("du", false, "compare the cache directory's disk usage with the logged totals")
+	actionSize       = flag.Int64("action-size", cachelog.DefaultActionSize, "estimate the size of action entries not recorded in the log as `bytes`")
+	clampSkew        = flag.Bool("clamp-skew", false, "treat negative reuse times caused by timestamps going backward as zero")
+	belady           = flag.Bool("belady", false, "with -lru-cap, also simulate the optimal (Belady MIN) policy")
+	buckets          = flag.String("buckets", "1h,6h,1d,7d", "reuse histogram bucket `boundaries` (comma-separated durations)")
+	weighted         = flag.Bool("weighted", false, "also print reuse percentiles weighted by entry size")
+	percentiles      = flag.String("percentiles", "10,20,30,40,50,60,70,80,90,95,99,99.9", "print reuse time `percentiles` (comma-separated)")
+	pretty           = flag.Bool("pretty", false, "with -json, indent the JSON output")
+	progressFlag     = flag.Bool("progress", false, "print progress to standard error while reading the log")
+	cpuProfile       = flag.String("cpuprofile", "", "write a CPU profile to `file`")
+	memProfile       = flag.String("memprofile", "", "write a memory profile to `file` at exit")
+	maxSize          = flag.Int64("max-size", 0, "ignore data objects larger than `bytes` and their actions")
+	minSize          = flag.Int64("min-size", 0, "ignore data objects smaller than `bytes` and their actions")
+	mrc              = flag.Bool("mrc", false, "print the LRU hit rate over a range of cache sizes (as CSV with -csv)")
+	stackDist        = flag.Bool("stack-distance", false, "print percentiles of the number of distinct entries used between reuses")
+	outFile          = flag.String("o", "", "write output to `file` instead of standard output")
+	hotCap           = flag.Int64("hot-cap", 0, "simulate a two-tier cache with a hot LRU tier of `bytes` (requires -cold-cap)")
+	coldCap          = flag.Int64("cold-cap", 0, "simulate a two-tier cache with a cold LRU tier of `bytes` (requires -hot-cap)")
+	lruCap           = flag.Int64("lru-cap", 0, "simulate an LRU cache holding at most `bytes` of data")
+	interarrival     = flag.Bool("interarrival", false, "print percentiles of the times between consecutive gets")
+	maxLines         = flag.Int("maxlines", 0, "read only the first `n` lines of each log")
+	large            = flag.Int64("large", 0, "list the data objects larger than `bytes`")
+	lenient          = flag.Bool("lenient", false, "skip malformed log lines instead of stopping at the first one")
+	rotated          = flag.Bool("rotated", false, "also read rotated logs (log.txt.1, log.txt.2, ...)")
+	ttl              = flag.String("ttl", "", "simulate evicting entries unused for `duration`")
+	ttlSweep         = flag.Bool("ttl-sweep", false, "simulate a range of TTLs and print a table of the results")
+	ttlPoints        = flag.String("ttl-points", "1d,2d,3d,7d,14d,30d,60d,90d", "TTL `durations` to simulate with -ttl-sweep (comma-separated)")
+	window           = flag.String("window", "", "report the working set size over a sliding window of `duration`")
+	warmup           = flag.String("warmup", "", "report how long a cold cache takes to warm up, using a sliding window of `duration`")
+	warmupFraction   = flag.Float64("warmup-fraction", 0.9, "with -warmup, the `fraction` of the final hit rate that counts as warm")
+	targetRate       = flag.Float64("target-hitrate", 0, "find the smallest TTL with a simulated hit rate of at least `fraction`")
+	hours            = flag.Bool("hours", false, "print a histogram of activity by hour of day")
+	human            = flag.Bool("human", false, "print byte counts in binary units (KiB, MiB, GiB)")
+	weekdays         = flag.Bool("weekdays", false, "print a summary of activity by day of week")
+	tz               = flag.String("tz", "Local", "use time `zone` for the log period, -hours, and -weekdays (Local, UTC, or a name like America/New_York)")
+	waste            = flag.Bool("waste", false, "print the data bytes written per UTC day and never reused")
+	cumulative       = flag.Bool("cumulative", false, "print the cumulative bytes reused per UTC day (as CSV with -csv)")
+	project          = flag.Int("project", 0, "estimate the cache size `days` after the end of the log")
+	tail             = flag.String("tail", "", "analyze only the last `duration` of the log")
+	timeline         = flag.Bool("timeline", false, "print a timeline of activity per UTC day")
+	putGetThreshold  = flag.Float64("put-get-threshold", 0, "with -timeline, flag days whose put/get ratio exceeds `ratio`")
+	top              = flag.Int("top", 0, "list the `n` largest data objects")
+	until            = flag.String("until", "", "ignore log lines after `time` (RFC3339, or duration before last event)")
+)
+
+// Repeatable flags.
+var (
+	cacheDirs stringList // -cachedir
+	logFiles  stringList // -logfile
+)
+
+func init() {
+	flag.Var(&cacheDirs, "cachedir", "read log.txt from `dir` instead of $GOCACHE (repeatable)")
+	flag.Var(&logFiles, "logfile", "read cache log from `file` (- for standard input; repeatable)")
+}
+
+// A stringList is a flag.Value that accumulates the values
+// of a flag given multiple times.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// units maps the -unit names to their length in seconds.
+var units = map[string]float64{
+	"seconds": 1,
+	"minutes": 60,
+	"hours":   3600,
+	"days":    86400,
+}
+
+// exitNoLog is the exit status when the cache has no log.
+const exitNoLog = 3
+
+// Values derived from the flags, set by main.
+var (
+	unitSize       float64        // length of the -unit in seconds
+	hists          []string       // -hist kinds
+	histBounds     []int64        // -buckets, in seconds
+	ttlSec         int64          // -ttl, in seconds
+	windowSec      int64          // -window, in seconds
+	warmupSec      int64          // -warmup, in seconds
+	tailSec        int64          // -tail, in seconds
+	sinceBound     timeBound      // -since
+	untilBound     timeBound      // -until
+	ttlSweepPoints []int64        // -ttl-points, in seconds
+	pctiles        []pctile       // -percentiles
+	scoreWeights   [3]float64     // -score-weights
+	cacheRoot      string         // cache directory, or "" when reading -logfile or several caches
+	tzLoc          *time.Location // -tz
 )
 
-type entry struct {
-	created    int64
-	lastReused int64
-	size       int64
-	reused     bool
-	data       *entry
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gocachelogstat [options]\n")
+	flag.PrintDefaults()
+	fmt.Fprint(os.Stderr, templateHelp)
+	os.Exit(2)
 }
 
 func main() {
 	log.SetPrefix("gocachelogstat:")
 	log.SetFlags(0)
-
-	out, err := exec.Command("go", "env", "GOCACHE").CombinedOutput()
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 0 {
+		usage()
+	}
+	startProfiles()
+	switch *format {
+	case "text", "markdown":
+	default:
+		fatalf("unknown -format %q", *format)
+	}
+	if count(*jsonFlag, *csvFlag, *promFlag, *summary, *compareFile != "", *format != "text", *templateFlag != "") > 1 {
+		fatalf("at most one of -json, -csv, -prom, -summary, -compare, -format, and -template may be given")
+	}
+	if *templateFlag != "" {
+		tmpl = parseTemplate(*templateFlag)
+	}
+	if *hist != "" {
+		hists = strings.Split(*hist, ",")
+		for _, h := range hists {
+			switch h {
+			case "size", "reuse":
+			default:
+				fatalf("unknown -hist %q", h)
+			}
+		}
+	}
+	windowSec = parseDurationFlag("window", *window)
+	warmupSec = parseDurationFlag("warmup", *warmup)
+	ttlSec = parseDurationFlag("ttl", *ttl)
+	tailSec = parseDurationFlag("tail", *tail)
+	sinceBound = parseTimeFlag("since", *since)
+	untilBound = parseTimeFlag("until", *until)
+	histBounds = parseDurations("buckets", *buckets)
+	ttlSweepPoints = parseDurations("ttl-points", *ttlPoints)
+	pctiles = parsePercentiles("percentiles", *percentiles)
+	scoreWeights = parseScoreWeights("score-weights", *scoreWeightsFlag)
+	if *pretty && !*jsonFlag && *listen == "" {
+		fatalf("-pretty requires -json")
+	}
+	if *putGetThreshold < 0 {
+		fatalf("invalid -put-get-threshold %v", *putGetThreshold)
+	}
+	if *minSize < 0 || *maxSize < 0 || *maxSize > 0 && *minSize > *maxSize {
+		fatalf("invalid -min-size %d and -max-size %d", *minSize, *maxSize)
+	}
+	if *actionSize <= 0 {
+		fatalf("invalid -action-size %d", *actionSize)
+	}
+	if (*hotCap > 0) != (*coldCap > 0) {
+		fatalf("-hot-cap and -cold-cap must be given together")
+	}
+	switch *quantileMethod {
+	case "nearest", "linear":
+	default:
+		fatalf("unknown -quantile-method %q", *quantileMethod)
+	}
+	if *warmupFraction <= 0 || *warmupFraction > 1 {
+		fatalf("invalid -warmup-fraction %v: must be between 0 and 1", *warmupFraction)
+	}
+	if *targetRate < 0 || *targetRate > 1 {
+		fatalf("invalid -target-hitrate %v: must be between 0 and 1", *targetRate)
+	}
+	loc, err := time.LoadLocation(*tz)
 	if err != nil {
-		log.Fatalf("go env GOCACHE: %v\n%s", err, out)
+		fatalf("invalid -tz: %v", err)
 	}
-	dir := strings.TrimSpace(string(out))
-	if dir == "" {
-		log.Fatalf("go env GOCACHE: no output (old Go version?)")
+	tzLoc = loc
+	unitSize = units[*unit]
+	if unitSize == 0 {
+		fatalf("unknown -unit %q", *unit)
 	}
-	if dir == "off" {
-		log.Fatalf("go env GOCACHE: GOCACHE=off")
+
+	var files []string
+	for _, file := range logFiles {
+		files = append(files, file)
+	}
+	for _, dir := range cacheDirs {
+		files = append(files, filepath.Join(dir, "log.txt"))
+	}
+	if len(files) == 0 {
+		cacheDirs = stringList{goCache()}
+		files = []string{filepath.Join(cacheDirs[0], "log.txt")}
+	}
+	if len(logFiles) == 0 && len(cacheDirs) == 1 {
+		cacheRoot = cacheDirs[0]
+	}
+	if *checkDisk && cacheRoot == "" {
+		fatalf("-check-disk requires a single -cachedir and no -logfile")
+	}
+	if *diskUsage && cacheRoot == "" {
+		fatalf("-du requires a single -cachedir and no -logfile")
+	}
+	stdin := 0
+	for _, file := range files {
+		if file == "-" {
+			stdin++
+		}
+	}
+	if stdin > 1 {
+		fatalf("standard input may be given only once")
+	}
+
+	if *watch > 0 {
+		if len(files) != 1 || files[0] == "-" || *outFile != "" {
+			fatalf("-watch requires a single log file and standard output")
+		}
+		watchLog(files[0])
+		exit(0)
+	}
+	if *listen != "" {
+		if stdin > 0 {
+			fatalf("-listen cannot read the log from standard input")
+		}
+		serve(*listen, files)
+		exit(0)
+	}
+
+	if *dump != "" {
+		if *dump != "ndjson" {
+			fatalf("unknown -dump format %q", *dump)
+		}
+		writeOutput(func(w io.Writer) { dumpRecords(w, files) })
+		exit(0)
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(dir, "log.txt"))
+	s, err := loadSources(files)
+	if pe, ok := err.(*os.PathError); ok && os.IsNotExist(err) && len(logFiles) == 0 {
+		fmt.Fprintf(os.Stderr, "gocachelogstat: %s does not exist.\n", pe.Path)
+		fmt.Fprintf(os.Stderr, "Cache logging is not enabled: only some releases of the go command,\n")
+		fmt.Fprintf(os.Stderr, "starting with Go 1.10, write a log of cache operations.\n")
+		fmt.Fprintf(os.Stderr, "Run builds with such a go command to create the log,\n")
+		fmt.Fprintf(os.Stderr, "or use -logfile to read a log saved elsewhere.\n")
+		exit(exitNoLog)
+	}
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
-	var totalA, totalReusedA, totalD, totalReusedD int64
+	writeOutput(func(w io.Writer) {
+		if *compareFile != "" {
+			other, err := loadStats(*compareFile)
+			if err != nil {
+				fatal(err)
+			}
+			printCompare(w, strings.Join(files, ","), s, *compareFile, other)
+		} else {
+			printStats(w, s)
+		}
+	})
+	exit(0)
+}
 
-	var reuseA, reuseD, reuseDeltaA, reuseDeltaD []int
-	var firstTime, lastTime int64
-	cache := make(map[string]*entry)
-	for _, line := range bytes.Split(data, []byte("\n")) {
-		f := strings.Fields(string(line))
-		if len(f) == 0 {
-			continue
+// writeOutput calls f to write to the -o file or standard output.
+func writeOutput(f func(io.Writer)) {
+	out := os.Stdout
+	if *outFile != "" {
+		file, err := os.Create(*outFile)
+		if err != nil {
+			fatal(err)
 		}
-		if len(f) < 3 || f[1] == "put" && len(f) != 5 {
-			log.Fatalf("invalid log.txt line: %v", string(line))
+		out = file
+	}
+	w := bufio.NewWriter(out)
+	f(w)
+	if err := w.Flush(); err != nil {
+		fatal(err)
+	}
+	if out != os.Stdout {
+		if err := out.Close(); err != nil {
+			fatal(err)
 		}
-		t, err := strconv.ParseInt(f[0], 10, 64)
+	}
+}
+
+// printStats prints s in the format selected by the flags.
+func printStats(w io.Writer, s *Stats) {
+	r := &Report{Stats: s}
+	switch {
+	case *csvFlag:
+		r.WriteCSV(w)
+	case *jsonFlag:
+		r.WriteJSON(w)
+	case *promFlag:
+		r.WriteProm(w)
+	case *summary:
+		r.WriteSummary(w)
+	case *format == "markdown":
+		r.WriteMarkdown(w)
+	case tmpl != nil:
+		r.WriteTemplate(w, tmpl)
+	default:
+		r.WriteText(w)
+	}
+}
+
+// count returns the number of true values in list.
+func count(list ...bool) int {
+	n := 0
+	for _, b := range list {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// loadStats reads the named log file, along with its rotated copies
+// if -rotated is set, and analyzes the records.
+func loadStats(file string) (*Stats, error) {
+	return loadSources([]string{file})
+}
+
+// loadSources reads the named log files as in loadStats
+// and analyzes their records merged into a single log.
+// An entry put in more than one log is counted only once.
+// If -by-source is set and there are several logs,
+// loadSources also analyzes each log separately.
+func loadSources(files []string) (*Stats, error) {
+	var records []cachelog.Record
+	var bySrc []sourceStats
+	var info scanInfo
+	for _, file := range files {
+		list, fi, err := loadRecords(file)
 		if err != nil {
-			log.Fatalf("invalid log.txt time: %v", string(line))
+			return nil, err
 		}
-		if firstTime == 0 {
-			firstTime = t
+		if *bySource && len(files) > 1 {
+			s := analyzeRecords(list)
+			s.Malformed, s.Truncated = fi.malformed, fi.truncated
+			bySrc = append(bySrc, sourceStats{file, s})
 		}
-		lastTime = t
-		switch f[1] {
-		case "put":
-			size, err := strconv.ParseInt(f[4], 10, 64)
-			if err != nil {
-				log.Fatalf("invalid log.txt size: %v", string(line))
-			}
-			e1 := cache[f[3]+"-d"]
-			if e1 == nil {
-				e1 = new(entry)
-				e1.created = t
-				e1.size = size
-				cache[f[3]+"-d"] = e1
-				totalD += size
-			}
-			e := cache[f[2]+"-a"]
-			if e == nil {
-				e = new(entry)
-				e.created = t
-				e.size = 154
-				e.data = e1
-				cache[f[2]+"-a"] = e
-				totalA += 154
+		records = append(records, list...)
+		info.malformed += fi.malformed
+		info.truncated = info.truncated || fi.truncated
+	}
+	if len(files) > 1 {
+		sort.SliceStable(records, func(i, j int) bool {
+			return records[i].Time.Before(records[j].Time)
+		})
+	}
+	s := analyzeRecords(records)
+	s.Malformed, s.Truncated = info.malformed, info.truncated
+	s.sources = bySrc
+	return s, nil
+}
+
+// loadRecords reads the records from the named log file,
+// along with its rotated copies if -rotated is set.
+// The records are tagged with file as their source.
+func loadRecords(file string) ([]cachelog.Record, scanInfo, error) {
+	var records []cachelog.Record
+	info, err := scanRecords(file, func(rec cachelog.Record) {
+		records = append(records, rec)
+	})
+	if err != nil {
+		return nil, scanInfo{}, err
+	}
+	return records, info, nil
+}
+
+// A scanInfo reports on the lines of a log that were not read as records.
+type scanInfo struct {
+	malformed int  // malformed lines skipped under -lenient
+	truncated bool // reading stopped at -maxlines
+}
+
+// scanRecords is like loadRecords but calls fn for each record
+// instead of accumulating them. With -maxlines, it reads
+// at most that many lines in total from the log and its rotated copies.
+func scanRecords(file string, fn func(cachelog.Record)) (scanInfo, error) {
+	files := []string{file}
+	if *rotated && file != "-" {
+		files = append(rotatedLogs(file), file)
+	}
+	var info scanInfo
+	left := *maxLines
+	for _, f := range files {
+		if *maxLines > 0 && left <= 0 {
+			info.truncated = true
+			break
+		}
+		p, err := scanLog(f, file, left, fn)
+		if err != nil {
+			return scanInfo{}, err
+		}
+		info.malformed += p.Malformed
+		if p.Truncated {
+			info.truncated = true
+			break
+		}
+		if left > 0 {
+			left -= p.Lines
+		}
+	}
+	return info, nil
+}
+
+// analyzeRecords analyzes the records in the -since/-until/-tail window.
+func analyzeRecords(records []cachelog.Record) *Stats {
+	var minTime, maxTime int64
+	if (sinceBound.set || untilBound.set || tailSec > 0) && len(records) > 0 {
+		last := records[len(records)-1].Time.Unix()
+		minTime = sinceBound.resolve(last)
+		maxTime = untilBound.resolve(last)
+		if tailSec > 0 && last-tailSec > minTime {
+			minTime = last - tailSec
+		}
+	}
+	lines := len(records)
+	excluded := 0
+	if minTime != 0 || maxTime != 0 {
+		var keep []cachelog.Record
+		for _, rec := range records {
+			if t := rec.Time.Unix(); t >= minTime && (maxTime == 0 || t <= maxTime) {
+				keep = append(keep, rec)
 			}
+		}
+		excluded = len(records) - len(keep)
+		records = keep
+	}
+	var filtered int
+	var filteredBytes int64
+	windowed := len(records)
+	if *minSize > 0 || *maxSize > 0 {
+		records, filtered, filteredBytes = filterSize(records, *minSize, *maxSize)
+	}
+
+	s := Analyze(records)
+	s.Read, s.Excluded = lines, excluded
+	s.Filtered, s.FilteredBytes, s.FilteredLines = filtered, filteredBytes, windowed-len(records)
+	return s
+}
 
-		case "get", "miss":
-			e := cache[f[2]+"-a"]
-			if e == nil {
+// filterSize returns the records without the puts of data objects
+// smaller than min or, if max is positive, larger than max.
+// It also drops the gets and misses of any action whose most recent put
+// was dropped, so that the action is excluded along with its data.
+// It returns the number and total size of the distinct objects dropped.
+func filterSize(records []cachelog.Record, min, max int64) (keep []cachelog.Record, objects int, bytes int64) {
+	dropped := make(map[string]bool) // action IDs whose last put was dropped
+	seen := make(map[string]bool)    // output IDs dropped
+	for _, rec := range records {
+		switch rec.Verb {
+		case cachelog.Put:
+			if rec.Size < min || max > 0 && rec.Size > max {
+				dropped[rec.ActionID] = true
+				if !seen[rec.OutputID] {
+					seen[rec.OutputID] = true
+					objects++
+					bytes += rec.Size
+				}
 				continue
 			}
-			if e.lastReused == 0 {
-				totalReusedA += e.size
-				e.lastReused = e.created
-			}
-			if e.data.lastReused == 0 {
-				totalReusedD += e.data.size
-				e.data.lastReused = e.data.created
+			delete(dropped, rec.ActionID)
+		case cachelog.Get, cachelog.Miss:
+			if dropped[rec.ActionID] {
+				continue
 			}
-			reuseA = append(reuseA, int(t-e.created))
-			reuseD = append(reuseD, int(t-e.data.created))
-			reuseDeltaA = append(reuseDeltaA, int(t-e.lastReused))
-			reuseDeltaD = append(reuseDeltaD, int(t-e.data.lastReused))
+		}
+		keep = append(keep, rec)
+	}
+	return keep, objects, bytes
+}
 
-			e.lastReused = t
-			e.data.lastReused = t
+// scanLog reads and parses the named log file,
+// calling fn for each record, tagged with source.
+// If maxLines is positive, scanLog stops after that many lines.
+// It returns the Parser, which records what was skipped.
+func scanLog(file, source string, maxLines int, fn func(cachelog.Record)) (*cachelog.Parser, error) {
+	var r io.Reader
+	var size int64
+	if file == "-" {
+		file = "stdin"
+		r = os.Stdin
+	} else {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
 		}
+		defer f.Close()
+		r = f
+		if info, err := f.Stat(); err == nil {
+			size = info.Size()
+		}
+	}
+	p := &cachelog.Parser{Name: file, Source: source, Warn: warn, Lenient: *lenient, MaxLines: maxLines}
+	if showProgress() {
+		var done func()
+		r, done = withProgress(p, r, size)
+		defer done()
 	}
+	err := p.Scan(r, fn)
+	return p, err
+}
 
-	sort.Ints(reuseA)
-	sort.Ints(reuseD)
-	sort.Ints(reuseDeltaA)
-	sort.Ints(reuseDeltaD)
+// warn prints a warning from the parser.
+func warn(msg string) {
+	log.Printf("warning: %s", msg)
+}
 
-	fmt.Printf("Please add the following output (including the quotes) to https://golang.org/issue/22990\n\n")
-	fmt.Printf("```\n")
-	defer fmt.Printf("```\n")
+// rotatedLogs returns the rotated copies of the named log file,
+// file.1, file.2, and so on (optionally gzipped, as in file.1.gz),
+// oldest first. Higher numbers are older.
+func rotatedLogs(file string) []string {
+	matches, err := filepath.Glob(file + ".*")
+	if err != nil {
+		fatal(err)
+	}
+	type rotated struct {
+		n    int
+		name string
+	}
+	var list []rotated
+	for _, m := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(m, file+"."), ".gz")
+		n, err := strconv.Atoi(suffix)
+		if err == nil && n > 0 {
+			list = append(list, rotated{n, m})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].n > list[j].n })
+	var names []string
+	for _, r := range list {
+		names = append(names, r.name)
+	}
+	return names
+}
 
-	fmt.Printf("cache age: %.2f days\n", float64(lastTime-firstTime)/86400)
-	printCache("action", totalA, totalReusedA, reuseA, reuseDeltaA)
-	printCache("data", totalD, totalReusedD, reuseD, reuseDeltaD)
+// printText prints the text report for s.
+func printText(w io.Writer, s *Stats) {
+	if !*quiet {
+		fmt.Fprintf(w, "Please add the following output (including the quotes) to https://golang.org/issue/22990\n\n")
+		fmt.Fprintf(w, "```\n")
+	}
+	if s.Lines == 0 && s.Read > 0 {
+		fmt.Fprintf(w, "no data: all %d records were filtered out\n", s.Read)
+	} else if s.Lines == 0 {
+		fmt.Fprintf(w, "no data: the log is empty\n")
+	} else {
+		printReport(w, s)
+	}
+	if !*quiet {
+		fmt.Fprintf(w, "```\n")
+	}
 }
 
-func printCache(name string, total, totalReused int64, reuse, reuseDelta []int) {
-	fmt.Printf("%s cache: %d bytes, %d reused\n", name, total, totalReused)
-	if len(reuse) == 0 {
-		fmt.Printf("\tno reuse\n")
+// printReport prints the body of the text report for s.
+func printReport(w io.Writer, s *Stats) {
+	fmt.Fprintf(w, "cache age: %.2f %s\n", float64(s.Age())/unitSize, *unit)
+	if s.End > 0 {
+		fmt.Fprintf(w, "\tfrom %s to %s\n", time.Unix(s.Start, 0).In(tzLoc).Format(time.RFC3339), time.Unix(s.End, 0).In(tzLoc).Format(time.RFC3339))
+	}
+	if span := s.SpanDays(); span > 0 {
+		fmt.Fprintf(w, "\tactive %d of %d days (%s)\n", len(s.Days), span, percent(int64(len(s.Days)), int64(span)))
+	}
+	if s.IdleEnd > s.IdleStart {
+		fmt.Fprintf(w, "\tlongest idle: %.2f %s, from %s to %s\n", float64(s.IdleEnd-s.IdleStart)/unitSize, *unit,
+			time.Unix(s.IdleStart, 0).In(tzLoc).Format(time.RFC3339), time.Unix(s.IdleEnd, 0).In(tzLoc).Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, "records: %d (%d put, %d get, %d miss, %d skipped)\n", s.Read, s.Puts, s.Hits.All, s.Misses.All, s.Skipped)
+	if s.Trims > 0 {
+		fmt.Fprintf(w, "\ttrims: %d, %s reclaimed\n", s.Trims, bytesLabel(s.Trimmed))
+	}
+	if s.Excluded > 0 {
+		fmt.Fprintf(w, "\ttime window: excluded %d records\n", s.Excluded)
+	}
+	if s.Filtered > 0 {
+		fmt.Fprintf(w, "\tsize filter: excluded %d data objects, %s (%d records)\n", s.Filtered, bytesLabel(s.FilteredBytes), s.FilteredLines)
+	}
+	if s.Truncated {
+		fmt.Fprintf(w, "\tpartial sample: stopped after -maxlines=%d lines\n", *maxLines)
+	}
+	if s.Malformed > 0 {
+		fmt.Fprintf(w, "\tmalformed: %d lines skipped; statistics are partial\n", s.Malformed)
+	}
+	if s.Backward > 0 {
+		note := "reuse times may be negative; use -clamp-skew"
+		if *clampSkew {
+			note = "negative reuse times clamped to zero"
+		}
+		fmt.Fprintf(w, "\tclock skew: %d timestamps went backward; %s\n", s.Backward, note)
+	}
+	fmt.Fprintf(w, "hit rate: %s (%d hits, %d misses)\n", hitRate(s.Hits.All, s.Misses.All), s.Hits.All, s.Misses.All)
+	fmt.Fprintf(w, "\tput in log: %s (%d hits, %d misses)\n", hitRate(s.Hits.Known, s.Misses.Known), s.Hits.Known, s.Misses.Known)
+	printCache(w, "action", &s.Action)
+	if *weighted && len(s.Reuses) > 0 {
+		age, delta := weightedReuses(s, "action")
+		printWeightedPercentiles(w, "size-weighted reuse time", age)
+		printWeightedPercentiles(w, "size-weighted reuse time delta", delta)
+	}
+	printCache(w, "data", &s.Data)
+	if *weighted && len(s.Reuses) > 0 {
+		age, delta := weightedReuses(s, "data")
+		printWeightedPercentiles(w, "size-weighted reuse time", age)
+		printWeightedPercentiles(w, "size-weighted reuse time delta", delta)
+	}
+	printReuseCounts(w, s)
+	printDedup(w, s)
+	fmt.Fprintf(w, "churn: %s of data bytes never reused\n", percent(s.Data.NeverReusedBytes, s.Data.Total))
+	if len(s.sources) > 0 {
+		printSources(w, s.sources)
+	}
+	if *score {
+		printScore(w, s, scoreWeights)
+	}
+	if *top > 0 {
+		printTop(w, s.Outputs, *top)
+	}
+	if *large > 0 {
+		printLarge(w, s.Outputs, *large)
+	}
+	if *checkDisk {
+		printDiskCheck(w, cacheRoot, s)
+	}
+	if *diskUsage {
+		printDiskUsage(w, cacheRoot, s)
+	}
+	if windowSec > 0 {
+		printWorkingSet(w, s.Accesses, windowSec)
+	}
+	if warmupSec > 0 {
+		printWarmup(w, s, warmupSec, *warmupFraction)
+	}
+	if ttlSec > 0 {
+		printTTL(w, s, ttlSec)
+	}
+	if *ttlSweep {
+		printTTLSweep(w, s, ttlSweepPoints)
+	}
+	if *targetRate > 0 {
+		printTargetTTL(w, s, *targetRate, s.Age()+1)
+	}
+	if *lruCap > 0 {
+		printLRU(w, s, *lruCap)
+		if *belady {
+			printMIN(w, s.Accesses, *lruCap)
+		}
+	}
+	if *mrc {
+		printMRC(w, s.Accesses, s.Data.Total)
+	}
+	if *stackDist {
+		printStackDistance(w, s.Accesses)
+	}
+	if *hotCap > 0 {
+		printTiers(w, s.Accesses, *hotCap, *coldCap)
+	}
+	if *interarrival {
+		printInterarrival(w, s)
+	}
+	if *hours {
+		printHours(w, s, tzLoc)
+	}
+	if *weekdays {
+		printWeekdays(w, s, tzLoc)
+	}
+	if *timeline {
+		printTimeline(w, s)
+	}
+	if *cumulative {
+		printCumulative(w, s)
+	}
+	if *waste {
+		printWaste(w, s)
+	}
+	if *decay {
+		printDecay(w, s, histBounds)
+	}
+	if *project > 0 {
+		printProjection(w, s, *project)
+	}
+	if *sizeReuse {
+		printSizeReuse(w, s)
+	}
+	if *cdf > 0 {
+		printCDF(w, s, *cdf)
+	}
+	for _, h := range hists {
+		switch h {
+		case "size":
+			printSizeHist(w, s.Outputs)
+		case "reuse":
+			printReuseHist(w, "action", s.Action.Reuse, histBounds)
+			printReuseHist(w, "data", s.Data.Reuse, histBounds)
+		}
+	}
+}
+
+// goCache returns the build cache directory reported by "go env GOCACHE".
+func goCache() string {
+	out, err := exec.Command("go", "env", "GOCACHE").CombinedOutput()
+	if err != nil {
+		fatalf("go env GOCACHE: %v\n%s", err, out)
+	}
+	dir := strings.TrimSpace(string(out))
+	if dir == "" {
+		fatalf("go env GOCACHE: no output (old Go version?)")
+	}
+	if dir == "off" {
+		fatalf("go env GOCACHE: GOCACHE=off")
+	}
+	return dir
+}
+
+// A timeBound is a parsed -since or -until value:
+// an absolute Unix time, or a duration before the last logged event.
+type timeBound struct {
+	set      bool  // flag was given
+	relative bool  // sec is a duration before the last event
+	sec      int64 // Unix time or duration in seconds
+}
+
+// resolve returns the bound as a Unix time,
+// given the time of the last logged event,
+// or 0 if the bound is not set.
+func (b timeBound) resolve(last int64) int64 {
+	if !b.relative {
+		return b.sec
+	}
+	return last - b.sec
+}
+
+// parseTimeFlag parses the value of the -since or -until flag,
+// an RFC3339 time or a duration before the last logged event.
+func parseTimeFlag(name, value string) timeBound {
+	if value == "" {
+		return timeBound{}
+	}
+	if d, err := parseDuration(value); err == nil {
+		return timeBound{set: true, relative: true, sec: int64(d / time.Second)}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		fatalf("invalid -%s %q: want RFC3339 time or duration", name, value)
+	}
+	return timeBound{set: true, sec: t.Unix()}
+}
+
+// hitRate returns the hit rate as a percentage string.
+func hitRate(hits, misses int) string {
+	return percent(int64(hits), int64(hits+misses))
+}
+
+// percent returns part as a percentage of whole,
+// or "n/a" if whole is zero.
+func percent(part, whole int64) string {
+	if whole == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(part)/float64(whole))
+}
+
+// parseDuration is like time.ParseDuration but also accepts
+// a whole number of days, such as "7d".
+func parseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parseDurationFlag parses the value of the named flag, a duration,
+// and returns the duration in seconds, or 0 if the value is empty.
+func parseDurationFlag(name, value string) int64 {
+	if value == "" {
+		return 0
+	}
+	d, err := parseDuration(value)
+	if err != nil || d < time.Second {
+		fatalf("invalid -%s %q", name, value)
+	}
+	return int64(d / time.Second)
+}
+
+// parseDurations parses the value of the named flag,
+// a comma-separated list of increasing durations,
+// and returns the durations in seconds.
+func parseDurations(name, value string) []int64 {
+	var list []int64
+	for _, s := range strings.Split(value, ",") {
+		d, err := parseDuration(s)
+		sec := int64(d / time.Second)
+		if err != nil || sec <= 0 || len(list) > 0 && sec <= list[len(list)-1] {
+			fatalf("invalid -%s %q: want increasing durations", name, value)
+		}
+		list = append(list, sec)
+	}
+	return list
+}
+
+// A pctile is a percentile to report, num/den.
+type pctile struct {
+	label    string // as given, like "99.9"
+	num, den int
+}
+
+// parsePercentiles parses the value of the named flag,
+// a comma-separated list of percentiles like 50 or 99.99.
+// Fractional percentiles are kept exact, as 9999/10000.
+func parsePercentiles(name, value string) []pctile {
+	var list []pctile
+	for _, s := range strings.Split(value, ",") {
+		digits := strings.Replace(s, ".", "", 1)
+		num, err := strconv.Atoi(digits)
+		den := 100
+		if i := strings.Index(s, "."); i >= 0 {
+			for range s[i+1:] {
+				den *= 10
+			}
+		}
+		if err != nil || strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") || num <= 0 || num >= den {
+			fatalf("invalid -%s %q: want percentiles between 0 and 100", name, value)
+		}
+		list = append(list, pctile{s, num, den})
+	}
+	return list
+}
+
+// printSummary prints a one-line summary of s, like
+//
+//	cache 42.30 days, 8.1GB data (62.0% reused), hit rate 88.0%
+//
+// The fields and their order are stable, for use by scripts.
+// If -maxlines cut the log short, the line ends with ", partial sample".
+func printSummary(w io.Writer, s *Stats) {
+	if s.Lines == 0 {
+		fmt.Fprintf(w, "cache: no data\n")
+		return
+	}
+	fmt.Fprintf(w, "cache %.2f %s, %s data (%s reused), hit rate %s",
+		float64(s.Age())/unitSize, *unit, bytesLabel(s.Data.Total), percent(s.Data.Reused, s.Data.Total), hitRate(s.Hits.All, s.Misses.All))
+	if s.Truncated {
+		fmt.Fprintf(w, ", partial sample")
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// printCache prints the statistics for one kind of cache entry.
+func printCache(w io.Writer, name string, c *cachelog.CacheStats) {
+	avg := int64(0)
+	if c.Entries > 0 {
+		avg = c.Total / int64(c.Entries)
+	}
+	fmt.Fprintf(w, "%s cache: %d entries, %s (average %s), %s reused (%s)\n",
+		name, c.Entries, bytesLabel(c.Total), bytesCell(avg), bytesCell(c.Reused), percent(c.Reused, c.Total))
+	if len(c.Sizes) > 0 {
+		fmt.Fprintf(w, "\tentry size: median %s, 90%% %s, max %s\n",
+			bytesCell(percentile(c.Sizes, 50, 100)), bytesCell(percentile(c.Sizes, 90, 100)), bytesCell(c.Sizes[len(c.Sizes)-1]))
+	}
+	fmt.Fprintf(w, "\tnever reused: %d entries, %s\n", c.NeverReused, bytesLabel(c.NeverReusedBytes))
+	if n := c.Entries - c.NeverReused; n > 0 {
+		fmt.Fprintf(w, "\treused: %d entries, %.2f reuses each on average\n", n, c.MeanReuses())
+	}
+	if len(c.Reuse) == 0 {
+		fmt.Fprintf(w, "\tno reuse\n")
 	} else {
-		fmt.Printf("\treuse time percentiles\n")
-		for i := 10; i <= 90; i += 10 {
-			j := len(reuse) * i / 100
-			fmt.Printf("\t\t%d%% %.2f days\n", i, float64(reuse[j])/86400)
-		}
-		fmt.Printf("\t\t95%% %.2f days\n", float64(reuse[len(reuse)*95/100])/86400)
-		fmt.Printf("\t\t99%% %.2f days\n", float64(reuse[len(reuse)*99/100])/86400)
-		fmt.Printf("\t\t99.9%% %.2f days\n", float64(reuse[len(reuse)*999/1000])/86400)
-		fmt.Printf("\t\tmax %.2f days\n", float64(reuse[len(reuse)-1])/86400)
-		fmt.Printf("\treuse time delta percentiles\n")
-		for i := 10; i <= 90; i += 10 {
-			j := len(reuseDelta) * i / 100
-			fmt.Printf("\t\t%d%% %.2f days\n", i, float64(reuseDelta[j])/86400)
-		}
-		fmt.Printf("\t\t95%% %.2f days\n", float64(reuseDelta[len(reuse)*95/100])/86400)
-		fmt.Printf("\t\t99%% %.2f days\n", float64(reuseDelta[len(reuse)*99/100])/86400)
-		fmt.Printf("\t\t99.9%% %.2f days\n", float64(reuseDelta[len(reuse)*999/1000])/86400)
-		fmt.Printf("\t\tmax %.2f days\n", float64(reuseDelta[len(reuse)-1])/86400)
+		printPercentiles(w, "reuse time", c.Reuse)
+		printPercentiles(w, "reuse time delta", c.ReuseDelta)
+	}
+}
+
+// printDedup prints how much sharing of data entries
+// between action entries saves.
+func printDedup(w io.Writer, s *Stats) {
+	if s.Data.Entries == 0 {
+		return
+	}
+	saved := 0.0
+	if s.Unshared > 0 {
+		saved = 100 * (1 - float64(s.Data.Total)/float64(s.Unshared))
+	}
+	fmt.Fprintf(w, "dedup: %d actions share %d data objects (%.2f actions per object), saving %.1f%% of data bytes\n",
+		s.Action.Entries, s.Data.Entries, float64(s.Action.Entries)/float64(s.Data.Entries), saved)
+}
+
+// printReuseCounts prints a table of the number of entries
+// in each cache by number of reuses.
+func printReuseCounts(w io.Writer, s *Stats) {
+	fmt.Fprintf(w, "reuse counts\n")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\treuses\taction\tdata\t\n")
+	lo := 0
+	for i, hi := range cachelog.ReuseCountBuckets {
+		var label string
+		switch {
+		case hi < 0:
+			label = fmt.Sprintf("%d+", lo)
+		case lo == hi:
+			label = fmt.Sprint(lo)
+		default:
+			label = fmt.Sprintf("%d-%d", lo, hi)
+		}
+		fmt.Fprintf(tw, "\t%s\t%d\t%d\t\n", label, s.Action.ReuseCounts[i], s.Data.ReuseCounts[i])
+		lo = hi + 1
+	}
+	tw.Flush()
+}
+
+// A quantiles is the summary of a sorted list of times, in seconds,
+// computed once so that every output format reports the same values.
+type quantiles struct {
+	pctiles []pctileValue // in the order requested
+	max     int64
+	median  int64
+	mean    float64
+	stddev  float64
+}
+
+// A pctileValue is the value of a single percentile.
+type pctileValue struct {
+	p     pctile
+	value int64
+}
+
+// exportPctiles are the percentiles reported by -json and -prom,
+// which do not depend on -percentiles so that their keys stay fixed.
+var exportPctiles = []pctile{
+	{"10", 10, 100}, {"20", 20, 100}, {"30", 30, 100}, {"40", 40, 100}, {"50", 50, 100},
+	{"60", 60, 100}, {"70", 70, 100}, {"80", 80, 100}, {"90", 90, 100},
+	{"95", 95, 100}, {"99", 99, 100}, {"99.9", 999, 1000},
+}
+
+// computeQuantiles returns the summary of the sorted list x
+// at the percentiles ps, or nil if x is empty.
+func computeQuantiles(x []int64, ps []pctile) *quantiles {
+	if len(x) == 0 {
+		return nil
+	}
+	q := &quantiles{
+		max:    x[len(x)-1],
+		median: percentile(x, 50, 100),
+	}
+	for _, p := range ps {
+		q.pctiles = append(q.pctiles, pctileValue{p, percentile(x, p.num, p.den)})
+	}
+	q.mean, q.stddev = meanStddev(x)
+	return q
+}
+
+// printPercentiles prints the percentiles of the sorted list x.
+func printPercentiles(w io.Writer, name string, x []int64) {
+	printQuantiles(w, name, computeQuantiles(x, pctiles))
+}
+
+// printQuantiles prints q, which may be nil, in the -unit.
+func printQuantiles(w io.Writer, name string, q *quantiles) {
+	fmt.Fprintf(w, "\t%s percentiles\n", name)
+	if q == nil {
+		return
+	}
+	for _, v := range q.pctiles {
+		fmt.Fprintf(w, "\t\t%s%% %.2f %s\n", v.p.label, float64(v.value)/unitSize, *unit)
+	}
+	fmt.Fprintf(w, "\t\tmax %.2f %s\n", float64(q.max)/unitSize, *unit)
+	fmt.Fprintf(w, "\t\tmedian %.2f %s\n", float64(q.median)/unitSize, *unit)
+	fmt.Fprintf(w, "\t\tmean %.2f %s\n", q.mean/unitSize, *unit)
+	fmt.Fprintf(w, "\t\tstddev %.2f %s\n", q.stddev/unitSize, *unit)
+}
+
+// A wsample is a sample x with weight w.
+type wsample struct {
+	x int64
+	w int64
+}
+
+// weightedReuses returns the reuse times and reuse time deltas
+// of the named kind of entry ("action" or "data"), sorted,
+// each weighted by the size of the reused entry.
+func weightedReuses(s *Stats, kind string) (age, delta []wsample) {
+	for _, r := range s.Reuses {
+		if kind == "action" {
+			age = append(age, wsample{r.ActionAge, r.ActionSize})
+			delta = append(delta, wsample{r.ActionDelta, r.ActionSize})
+		} else {
+			age = append(age, wsample{r.DataAge, r.DataSize})
+			delta = append(delta, wsample{r.DataDelta, r.DataSize})
+		}
+	}
+	for _, x := range [][]wsample{age, delta} {
+		sort.Slice(x, func(i, j int) bool { return x[i].x < x[j].x })
+	}
+	return age, delta
+}
+
+// weightedPercentile returns the num/den'th percentile of the
+// sorted weighted samples x: the first sample at which the
+// cumulative weight exceeds num/den of the total weight.
+// With unit weights it agrees with percentile.
+func weightedPercentile(x []wsample, num, den int) int64 {
+	var total int64
+	for _, s := range x {
+		total += s.w
+	}
+	target := total * int64(num) / int64(den)
+	var sum int64
+	for _, s := range x {
+		sum += s.w
+		if sum > target {
+			return s.x
+		}
+	}
+	return x[len(x)-1].x
+}
+
+// printWeightedPercentiles prints the -percentiles of the
+// sorted weighted samples x, which must be non-empty.
+func printWeightedPercentiles(w io.Writer, name string, x []wsample) {
+	fmt.Fprintf(w, "\t%s percentiles\n", name)
+	for _, p := range pctiles {
+		fmt.Fprintf(w, "\t\t%s%% %.2f %s\n", p.label, float64(weightedPercentile(x, p.num, p.den))/unitSize, *unit)
+	}
+}
+
+// meanStddev returns the mean and population standard deviation of x.
+// It returns zeros if x is empty.
+func meanStddev(x []int64) (mean, stddev float64) {
+	if len(x) == 0 {
+		return 0, 0
+	}
+	var sum, sumsq float64
+	for _, v := range x {
+		sum += float64(v)
+		sumsq += float64(v) * float64(v)
+	}
+	n := float64(len(x))
+	mean = sum / n
+	variance := sumsq/n - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	return mean, math.Sqrt(variance)
+}
+
+// percentile returns the num/den'th percentile of the sorted list x.
+// It returns 0 if x is empty.
+//
+// With -quantile-method=nearest, the default, percentile uses the
+// nearest-rank method, returning the sample at index len(x)*num/den.
+// With -quantile-method=linear, it interpolates linearly between
+// the two samples bracketing position (len(x)-1)*num/den, the method
+// used by most spreadsheets, rounding the result to an integer.
+func percentile(x []int64, num, den int) int64 {
+	if len(x) == 0 {
+		return 0
+	}
+	if *quantileMethod == "linear" {
+		pos := (len(x) - 1) * num
+		i, rem := pos/den, pos%den
+		if rem == 0 || i+1 >= len(x) {
+			return x[i]
+		}
+		return x[i] + int64(math.Floor(float64(x[i+1]-x[i])*float64(rem)/float64(den)+0.5))
+	}
+	i := len(x) * num / den
+	if i >= len(x) {
+		i = len(x) - 1
 	}
+	return x[i]
 }
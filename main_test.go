@@ -0,0 +1,524 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+const testLog = `1000 put a1 d1 5000
+1100 put a2 d2 3000
+1200 put a3 d1 5000
+2000 get a1
+3000 miss a9
+4000 get a2
+5000 get a1
+`
+
+func analyzeString(t *testing.T, log string) *Stats {
+	t.Helper()
+	records, err := cachelog.ParseLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Analyze(records)
+}
+
+// saveGlobals saves the package globals that tests set
+// to control formatting and restores them when tb finishes.
+func saveGlobals(tb testing.TB) {
+	oldUnitSize, oldUnit, oldPctiles, oldQuiet, oldTZ := unitSize, *unit, pctiles, *quiet, tzLoc
+	oldHists, oldHistBounds, oldTTLPoints := hists, histBounds, ttlSweepPoints
+	oldTTL, oldWindow, oldSince, oldUntil := ttlSec, windowSec, sinceBound, untilBound
+	oldHuman, oldSI := *human, *si
+	tb.Cleanup(func() {
+		unitSize, *unit, pctiles, *quiet, tzLoc = oldUnitSize, oldUnit, oldPctiles, oldQuiet, oldTZ
+		hists, histBounds, ttlSweepPoints = oldHists, oldHistBounds, oldTTLPoints
+		ttlSec, windowSec, sinceBound, untilBound = oldTTL, oldWindow, oldSince, oldUntil
+		*human, *si = oldHuman, oldSI
+	})
+}
+
+func TestAnalyzePercentiles(t *testing.T) {
+	s := analyzeString(t, testLog)
+	if p := percentile(s.Data.Reuse, 50, 100); p != 2900 {
+		t.Errorf("median reuse = %d, want 2900", p)
+	}
+	if p := percentile(s.Data.ReuseDelta, 999, 1000); p != 3000 {
+		t.Errorf("99.9th percentile reuse delta = %d, want 3000", p)
+	}
+	s = analyzeString(t, "1000 put a1 d1 5000\n3000001000 get a1\n6000001000 get a1\n")
+	if p := percentile(s.Data.ReuseDelta, 50, 100); p != 3000000000 {
+		t.Errorf("median delta = %d, want 3000000000", p)
+	}
+}
+
+func TestPrintCacheMismatchedLengths(t *testing.T) {
+	saveGlobals(t)
+	unitSize = 1
+	*unit = "seconds"
+	pctiles = parsePercentiles("percentiles", *percentiles)
+
+	var reuse, reuseDelta []int64
+	for i := int64(1); i <= 1000; i++ {
+		reuse = append(reuse, i)
+	}
+	reuseDelta = []int64{1, 2, 3}
+
+	var buf bytes.Buffer
+	printCache(&buf, "data", &cachelog.CacheStats{Total: 100, Reused: 50, Reuse: reuse, ReuseDelta: reuseDelta})
+	out := buf.String()
+	i := strings.Index(out, "reuse time delta percentiles\n")
+	if i < 0 {
+		t.Fatalf("missing delta percentiles:\n%s", out)
+	}
+	delta := out[i:]
+	for _, want := range []string{"\t\t99.9% 3.00 seconds\n", "\t\tmax 3.00 seconds\n"} {
+		if !strings.Contains(delta, want) {
+			t.Errorf("delta percentiles missing %q:\n%s", want, delta)
+		}
+	}
+	if !strings.Contains(out[:i], "\t\tmax 1000.00 seconds\n") {
+		t.Errorf("reuse percentiles missing max:\n%s", out[:i])
+	}
+}
+
+func TestPrintCacheReusedPercent(t *testing.T) {
+	for _, tt := range []struct {
+		c    cachelog.CacheStats
+		want string
+	}{
+		{cachelog.CacheStats{Entries: 2, Total: 1000, Reused: 632}, "632 reused (63.2%)\n"},
+		{cachelog.CacheStats{}, "0 reused (n/a)\n"},
+	} {
+		var buf bytes.Buffer
+		printCache(&buf, "data", &tt.c)
+		if out := buf.String(); !strings.Contains(out, tt.want) {
+			t.Errorf("printCache(%+v) missing %q:\n%s", tt.c, tt.want, out)
+		}
+	}
+}
+
+func TestParsePercentiles(t *testing.T) {
+	list := parsePercentiles("percentiles", "50,99.9,99.99")
+	want := []pctile{{"50", 50, 100}, {"99.9", 999, 1000}, {"99.99", 9999, 10000}}
+	if len(list) != len(want) {
+		t.Fatalf("parsePercentiles = %v, want %v", list, want)
+	}
+	for i := range want {
+		if list[i] != want[i] {
+			t.Errorf("parsePercentiles[%d] = %v, want %v", i, list[i], want[i])
+		}
+	}
+}
+
+func TestWeightedPercentile(t *testing.T) {
+	var x, unit []wsample
+	var plain []int64
+	for i := int64(1); i <= 100; i++ {
+		x = append(x, wsample{i, i})
+		unit = append(unit, wsample{i, 1})
+		plain = append(plain, i)
+	}
+	for _, p := range []int{10, 50, 90, 99} {
+		if got, want := weightedPercentile(unit, p, 100), percentile(plain, p, 100); got != want {
+			t.Errorf("weightedPercentile(unit, %d) = %d, want %d", p, got, want)
+		}
+	}
+	// Total weight is 5050; half of it is reached at sample 71.
+	if got := weightedPercentile(x, 50, 100); got != 71 {
+		t.Errorf("weightedPercentile(x, 50) = %d, want 71", got)
+	}
+}
+
+func TestPrintSingleTimestamp(t *testing.T) {
+	saveGlobals(t)
+	flags := []*bool{timeline, cumulative, decay, hours, weekdays, interarrival, weighted, ttlSweep}
+	oldFlags := make([]bool, len(flags))
+	for i, b := range flags {
+		oldFlags[i] = *b
+	}
+	oldTarget, oldLRU, oldHot, oldCold, oldProject := *targetRate, *lruCap, *hotCap, *coldCap, *project
+	t.Cleanup(func() {
+		for i, b := range flags {
+			*b = oldFlags[i]
+		}
+		*targetRate, *lruCap, *hotCap, *coldCap, *project = oldTarget, oldLRU, oldHot, oldCold, oldProject
+	})
+
+	unitSize = 86400
+	*unit = "days"
+	pctiles = parsePercentiles("percentiles", *percentiles)
+	histBounds = parseDurations("buckets", *buckets)
+	ttlSweepPoints = parseDurations("ttl-points", *ttlPoints)
+	hists = []string{"size", "reuse"}
+	tzLoc = time.UTC
+	ttlSec, windowSec = 86400, 3600
+	*quiet = true
+	for _, b := range flags {
+		*b = true
+	}
+	*targetRate, *lruCap, *hotCap, *coldCap, *project = 0.5, 100, 10, 10000, 7
+
+	for _, log := range []string{
+		"1000 put a1 d1 5000\n",
+		"1000 put a1 d1 5000\n1000 get a1\n1000 miss a2\n",
+	} {
+		s := analyzeString(t, log)
+		var buf bytes.Buffer
+		printText(&buf, s)
+		printSummary(&buf, s)
+		printCompare(&buf, "a", s, "b", s)
+		out := buf.String()
+		if strings.Contains(out, "NaN") || strings.Contains(out, "Inf") {
+			t.Errorf("output for %q contains NaN or Inf:\n%s", log, out)
+		}
+	}
+}
+
+func TestPrintEmptyAndOneEvent(t *testing.T) {
+	saveGlobals(t)
+	unitSize = 86400
+	*unit = "days"
+	*quiet = true
+	for _, tt := range []struct {
+		log, want string
+	}{
+		{"", "no data: the log is empty\n"},
+		{"1000 get a1\n", "records: 1 (0 put, 1 get, 0 miss, 0 skipped)\n"},
+		{"1000 put a1 d1 5000\n", "records: 1 (1 put, 0 get, 0 miss, 0 skipped)\n"},
+	} {
+		s := analyzeString(t, tt.log)
+		var buf bytes.Buffer
+		tzLoc = time.UTC
+		printText(&buf, s)
+		printSummary(&buf, s)
+		printJSON(&buf, newJSONStats(s))
+		printProm(&buf, s)
+		printCSV(&buf, s)
+		if out := buf.String(); !strings.Contains(out, tt.want) {
+			t.Errorf("output for %q missing %q:\n%s", tt.log, tt.want, out)
+		}
+	}
+}
+
+func TestPercentileSmall(t *testing.T) {
+	if p := percentile(nil, 999, 1000); p != 0 {
+		t.Errorf("percentile(nil) = %d, want 0", p)
+	}
+	for _, n := range []int{1, 2, 3} {
+		if p := percentile([]int64{7}, n*333, 1000); p != 7 {
+			t.Errorf("percentile([7], %d/1000) = %d, want 7", n*333, p)
+		}
+	}
+}
+
+func TestPercentileLinear(t *testing.T) {
+	old := *quantileMethod
+	t.Cleanup(func() { *quantileMethod = old })
+	*quantileMethod = "linear"
+	x := []int64{10, 20, 30, 40}
+	for _, tt := range []struct {
+		num, den int
+		want     int64
+	}{
+		{0, 100, 10},
+		{50, 100, 25},
+		{90, 100, 37},
+		{999, 1000, 40},
+	} {
+		if p := percentile(x, tt.num, tt.den); p != tt.want {
+			t.Errorf("percentile(%v, %d/%d) = %d, want %d", x, tt.num, tt.den, p, tt.want)
+		}
+	}
+}
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func TestPrintCacheGolden(t *testing.T) {
+	saveGlobals(t)
+	unitSize = float64(24 * time.Hour / time.Second)
+	*unit = "days"
+	pctiles = parsePercentiles("percentiles", *percentiles)
+
+	data, err := ioutil.ReadFile("testdata/reuse.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &cachelog.Parser{Name: "reuse.txt"}
+	records, err := p.Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Analyze(records)
+	var buf bytes.Buffer
+	printCache(&buf, "action", &s.Action)
+	printCache(&buf, "data", &s.Data)
+
+	const golden = "testdata/printcache.golden"
+	if *update {
+		if err := ioutil.WriteFile(golden, buf.Bytes(), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("printCache output differs from %s:\nhave:\n%s\nwant:\n%s", golden, buf.Bytes(), want)
+	}
+}
+
+func TestEfficiencyScore(t *testing.T) {
+	s := analyzeString(t, testLog)
+	score, _, ok := efficiencyScore(s, [3]float64{1, 0, 0})
+	if !ok || score != 75 {
+		t.Errorf("hit rate only: score %v, %v, want 75", score, ok)
+	}
+
+	// A log with no gets has no hit rate; the other weights are rescaled.
+	s = analyzeString(t, "1000 put a1 d1 5000\n")
+	score, components, ok := efficiencyScore(s, [3]float64{50, 30, 20})
+	if !ok || score != 0 || components[0].ok {
+		t.Errorf("no gets: score %v, %v, components %+v, want 0 without hit rate", score, ok, components)
+	}
+	if _, _, ok := efficiencyScore(analyzeString(t, ""), [3]float64{50, 30, 20}); ok {
+		t.Errorf("empty log: score ok, want n/a")
+	}
+}
+
+func TestPrintTTLMatchesHitRate(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/reuse.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := analyzeString(t, string(data))
+	for _, ttl := range []int64{600, 3600, 86400, 7 * 86400} {
+		var buf bytes.Buffer
+		printTTL(&buf, s, ttl)
+		want := fmt.Sprintf(": %.1f%% hit rate", 100*s.HitRate(time.Duration(ttl)*time.Second))
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("printTTL(%ds) = %q, want %q", ttl, buf.String(), want)
+		}
+	}
+}
+
+func TestWarmupTime(t *testing.T) {
+	// a1 and a2 are put before the log starts, so their early gets
+	// miss in a cold cache; a3 is put in the log and hits throughout.
+	s := analyzeString(t, "0 put a3 d3 100\n"+
+		"100 get a1\n200 get a2\n300 get a3\n400 get a1\n"+
+		"1000 put a1 d1 100\n1100 get a1\n1200 get a3\n1300 get a1\n1400 get a3\n")
+	for _, tt := range []struct{ window, at int64 }{
+		{500, 1100},  // the window at 1100 holds only the hit at 1100
+		{1000, 1400}, // the windows at 1100 to 1300 still hold a miss
+	} {
+		at, final, ok := warmupTime(s.Ops, tt.window, 0.9)
+		if !ok || at != tt.at || final != 1 {
+			t.Errorf("warmupTime(%d) = %d, %v, %v, want %d, 1, true", tt.window, at, final, ok, tt.at)
+		}
+	}
+	if _, _, ok := warmupTime(s.Ops, 5000, 0.9); ok {
+		t.Errorf("warmupTime with window longer than log: ok = true, want false")
+	}
+}
+
+func BenchmarkComputeQuantiles(b *testing.B) {
+	saveGlobals(b)
+	pctiles = parsePercentiles("percentiles", "10,20,30,40,50,60,70,80,90,95,99,99.9")
+	x := make([]int64, 1000000)
+	v := uint32(1)
+	for i := range x {
+		v = v*1664525 + 1013904223
+		x[i] = int64(v >> 8)
+	}
+	slices.Sort(x)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		computeQuantiles(x, pctiles)
+	}
+}
+
+func TestAnalyzeRecordsFilteredOut(t *testing.T) {
+	records, err := cachelog.ParseLog(strings.NewReader(testLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	saveGlobals(t)
+	sinceBound = parseTimeFlag("since", "2030-01-01T00:00:00Z")
+	s := analyzeRecords(records)
+	if s.Lines != 0 || s.Read != 7 || s.Excluded != 7 || s.Skipped != 0 || s.SpanDays() != 0 {
+		t.Errorf("analyzeRecords outside window: Lines=%d Read=%d Excluded=%d Skipped=%d SpanDays=%d, want 0, 7, 7, 0, 0",
+			s.Lines, s.Read, s.Excluded, s.Skipped, s.SpanDays())
+	}
+	*quiet = true
+	want := "no data: all 7 records were filtered out\n"
+	for _, print := range []func(io.Writer, *Stats){printText, printMarkdown} {
+		var buf bytes.Buffer
+		print(&buf, s)
+		if buf.String() != want {
+			t.Errorf("output outside window = %q, want %q", buf.String(), want)
+		}
+	}
+}
+
+func TestTailerLineNumbers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocachelogstat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "log.txt")
+	appendLog := func(text string) {
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(text)
+		f.Close()
+	}
+
+	appendLog("1000 put a1 d1 5000\n1100 get a1\n")
+	tl := newTailer(file)
+	if err := tl.update(); err != nil {
+		t.Fatal(err)
+	}
+	appendLog("1200 get a1\nbad line\n")
+	err = tl.update()
+	if err == nil || !strings.Contains(err.Error(), "log.txt:4:") {
+		t.Errorf("update with malformed line 4: err = %v, want log.txt:4 error", err)
+	}
+
+	oldLenient := *lenient
+	t.Cleanup(func() { *lenient = oldLenient })
+	*lenient = true
+	tl = newTailer(file)
+	if err := tl.update(); err != nil {
+		t.Fatalf("lenient update: %v", err)
+	}
+	if len(tl.records) != 3 || tl.parser.Malformed != 1 || tl.lines != 4 {
+		t.Errorf("lenient update: %d records, %d malformed, %d lines, want 3, 1, 4", len(tl.records), tl.parser.Malformed, tl.lines)
+	}
+}
+
+func TestTailerGzip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocachelogstat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(testLog))
+	zw.Close()
+	file := filepath.Join(dir, "log.txt.gz")
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	err = newTailer(file).update()
+	if err == nil || !strings.Contains(err.Error(), "gzip") {
+		t.Errorf("update of gzip log: err = %v, want gzip error", err)
+	}
+}
+
+func TestCompareLabels(t *testing.T) {
+	for _, tt := range []struct {
+		name1, name2   string
+		label1, label2 string
+	}{
+		{"old.txt", "new.txt", "old.txt", "new.txt"},
+		{"/tmp/old.txt", "/tmp/new.txt", "old.txt", "new.txt"},
+		{"before/log.txt", "after/log.txt", "before/log.txt", "after/log.txt"},
+		{"x/before/log.txt", "y/after/log.txt", "before/log.txt", "after/log.txt"},
+		{"a/log.txt,b/log.txt", "c/log.txt", "a/log.txt,b/log.txt", "c/log.txt"},
+		{"log.txt", "./log.txt", "log.txt", "./log.txt"},
+		{"log.txt", "log.txt", "log.txt", "log.txt"},
+	} {
+		label1, label2 := compareLabels(tt.name1, tt.name2)
+		if label1 != tt.label1 || label2 != tt.label2 {
+			t.Errorf("compareLabels(%q, %q) = %q, %q, want %q, %q",
+				tt.name1, tt.name2, label1, label2, tt.label1, tt.label2)
+		}
+	}
+}
+
+func TestPrintSummary(t *testing.T) {
+	saveGlobals(t)
+	unitSize = 86400
+	*unit = "days"
+	s := analyzeString(t, "1000 put a1 d1 8000\n87400 get a1\n")
+	for _, tt := range []struct {
+		human, si bool
+		want      string
+	}{
+		{false, false, "cache 1.00 days, 8000 bytes data (100.0% reused), hit rate 100.0%\n"},
+		{true, false, "cache 1.00 days, 7.8 KiB data (100.0% reused), hit rate 100.0%\n"},
+		{false, true, "cache 1.00 days, 8.0 KB data (100.0% reused), hit rate 100.0%\n"},
+	} {
+		*human, *si = tt.human, tt.si
+		var buf bytes.Buffer
+		printSummary(&buf, s)
+		if buf.String() != tt.want {
+			t.Errorf("printSummary with -human=%v -si=%v = %q, want %q", tt.human, tt.si, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestSimulateMIN(t *testing.T) {
+	s := analyzeString(t, `1000 put a1 d1 4000
+1001 put a2 d2 4000
+1002 get a1
+1003 put a3 d3 4000
+1004 get a1
+1005 get a2
+1006 put a4 d4 4000
+1007 get a3
+1008 get a1
+1009 miss a3
+1010 get a9
+`)
+	r := simulateMIN(s.Accesses, 10000)
+	// Putting d3 evicts d3 itself, whose next use is farthest away;
+	// d4 is never reused and d2 not again, so one of them goes,
+	// and the get of a3 at 1007 then evicts the other.
+	want := cachelog.SimResult{
+		Hits:        4,
+		Misses:      2,
+		Lost:        1,
+		Evictions:   3,
+		Evicted:     12000,
+		BytesServed: 16000,
+		Peak:        8000,
+	}
+	if r != want {
+		t.Errorf("simulateMIN = %+v, want %+v", r, want)
+	}
+
+	data, err := ioutil.ReadFile("testdata/reuse.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = analyzeString(t, string(data))
+	for _, max := range []int64{1000, 10000, 100000, 1000000, 1 << 40} {
+		min, lru := simulateMIN(s.Accesses, max), s.SimulateLRU(max)
+		if min.Hits < lru.Hits {
+			t.Errorf("cap %d: simulateMIN has %d hits, fewer than SimulateLRU's %d", max, min.Hits, lru.Hits)
+		}
+	}
+}
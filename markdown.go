@@ -0,0 +1,86 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+// printMarkdown prints the statistics for s as GitHub-flavored
+// Markdown tables, for -format=markdown.
+func printMarkdown(w io.Writer, s *Stats) {
+	if s.Lines == 0 && s.Read > 0 {
+		fmt.Fprintf(w, "no data: all %d records were filtered out\n", s.Read)
+		return
+	}
+	if s.Lines == 0 {
+		fmt.Fprintf(w, "no data: the log is empty\n")
+		return
+	}
+	mdRow(w, "statistic", "value")
+	mdRule(w, 2)
+	mdRow(w, "cache age", fmt.Sprintf("%.2f %s", float64(s.Age())/unitSize, *unit))
+	mdRow(w, "records", fmt.Sprint(s.Read))
+	mdRow(w, "hit rate", fmt.Sprintf("%s (%d hits, %d misses)", hitRate(s.Hits.All, s.Misses.All), s.Hits.All, s.Misses.All))
+	mdRow(w, "hit rate, put in log", fmt.Sprintf("%s (%d hits, %d misses)", hitRate(s.Hits.Known, s.Misses.Known), s.Hits.Known, s.Misses.Known))
+
+	fmt.Fprintf(w, "\n")
+	mdRow(w, "cache", "entries", "size", "reused", "never reused")
+	mdRule(w, 5)
+	for _, c := range []struct {
+		name string
+		c    *cachelog.CacheStats
+	}{{"action", &s.Action}, {"data", &s.Data}} {
+		mdRow(w, c.name, fmt.Sprint(c.c.Entries), bytesLabel(c.c.Total),
+			fmt.Sprintf("%s (%s)", bytesLabel(c.c.Reused), percent(c.c.Reused, c.c.Total)),
+			fmt.Sprintf("%d entries, %s", c.c.NeverReused, bytesLabel(c.c.NeverReusedBytes)))
+	}
+
+	fmt.Fprintf(w, "\n")
+	if len(s.Action.Reuse) == 0 {
+		fmt.Fprintf(w, "no reuse\n")
+		return
+	}
+	qs := []*quantiles{
+		computeQuantiles(s.Action.Reuse, pctiles),
+		computeQuantiles(s.Action.ReuseDelta, pctiles),
+		computeQuantiles(s.Data.Reuse, pctiles),
+		computeQuantiles(s.Data.ReuseDelta, pctiles),
+	}
+	mdRow(w, "percentile", "action reuse time", "action reuse time delta", "data reuse time", "data reuse time delta")
+	mdRule(w, 5)
+	row := func(label string, value func(q *quantiles) float64) {
+		cells := []string{label}
+		for _, q := range qs {
+			cells = append(cells, fmt.Sprintf("%.2f %s", value(q)/unitSize, *unit))
+		}
+		mdRow(w, cells...)
+	}
+	for i, p := range pctiles {
+		row(p.label+"%", func(q *quantiles) float64 { return float64(q.pctiles[i].value) })
+	}
+	row("max", func(q *quantiles) float64 { return float64(q.max) })
+	row("median", func(q *quantiles) float64 { return float64(q.median) })
+	row("mean", func(q *quantiles) float64 { return q.mean })
+	row("stddev", func(q *quantiles) float64 { return q.stddev })
+}
+
+// mdRow prints a Markdown table row with the given cells.
+func mdRow(w io.Writer, cells ...string) {
+	for i, c := range cells {
+		cells[i] = strings.Replace(c, "|", `\|`, -1)
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+}
+
+// mdRule prints the delimiter row for a Markdown table of n columns,
+// left-aligning the first column and right-aligning the rest.
+func mdRule(w io.Writer, n int) {
+	fmt.Fprintf(w, "| --- |%s\n", strings.Repeat(" ---: |", n-1))
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/pprof"
+)
+
+// atExitFuncs are run by exit before the program exits.
+var atExitFuncs []func()
+
+// atExit arranges for f to be run when the program exits.
+func atExit(f func()) {
+	atExitFuncs = append(atExitFuncs, f)
+}
+
+// exit runs the atExit functions and exits with the given status.
+func exit(code int) {
+	for _, f := range atExitFuncs {
+		f()
+	}
+	os.Exit(code)
+}
+
+// fatal is like log.Fatal but runs the atExit functions.
+func fatal(v ...interface{}) {
+	log.Print(v...)
+	exit(1)
+}
+
+// fatalf is like log.Fatalf but runs the atExit functions.
+func fatalf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+	exit(1)
+}
+
+// startProfiles starts the -cpuprofile profile and arranges
+// for it and the -memprofile profile to be written at exit,
+// including on interrupt, which is how -watch and -listen end.
+func startProfiles() {
+	if *cpuProfile == "" && *memProfile == "" {
+		return
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		<-c
+		exit(130)
+	}()
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			fatal(err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			fatal(err)
+		}
+		atExit(func() {
+			pprof.StopCPUProfile()
+			if err := f.Close(); err != nil {
+				log.Print(err)
+			}
+		})
+	}
+	if *memProfile != "" {
+		atExit(func() {
+			f, err := os.Create(*memProfile)
+			if err != nil {
+				log.Print(err)
+				return
+			}
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				log.Print(err)
+			}
+			if err := f.Close(); err != nil {
+				log.Print(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+// showProgress reports whether to print progress while reading logs:
+// only if -progress is set, standard error is a terminal,
+// and the output is not meant for another program.
+func showProgress() bool {
+	if !*progressFlag || *jsonFlag || *csvFlag || *promFlag {
+		return false
+	}
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// A progressReader counts the bytes read from r.
+type progressReader struct {
+	r io.Reader
+	n int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	return n, err
+}
+
+// withProgress arranges for p to print progress to standard error
+// while parsing f, which has the given size (or 0 if unknown),
+// and returns the reader to parse.
+// The returned function clears the progress line.
+func withProgress(p *cachelog.Parser, f io.Reader, size int64) (io.Reader, func()) {
+	pr := &progressReader{r: f}
+	p.Progress = func(lines int) {
+		if size > 0 {
+			fmt.Fprintf(os.Stderr, "\r%s: %d lines (%d%%)", p.Name, lines, 100*pr.n/size)
+		} else {
+			fmt.Fprintf(os.Stderr, "\r%s: %d lines", p.Name, lines)
+		}
+	}
+	return pr, func() { fmt.Fprintf(os.Stderr, "\r\x1b[K") }
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// printProm prints the statistics in the Prometheus text exposition format,
+// suitable for the node_exporter textfile collector.
+// All times are in seconds, regardless of -unit. The metrics are:
+//
+//	gocachelogstat_age_seconds                    time spanned by the log
+//	gocachelogstat_hits                           number of get operations
+//	gocachelogstat_misses                         number of miss operations
+//	gocachelogstat_hit_ratio                      hits / (hits + misses)
+//	gocachelogstat_bytes{cache}                   total size of entries
+//	gocachelogstat_reused_bytes{cache}            total size of reused entries
+//	gocachelogstat_reuse_age_seconds{cache,quantile}    entry age at reuse
+//	gocachelogstat_reuse_delta_seconds{cache,quantile}  time since previous reuse
+//
+// The cache label is "action" or "data". The quantile label ranges
+// from 0.1 to 1, where 1 is the maximum.
+func printProm(w io.Writer, s *Stats) {
+	gauge := func(name, help string) {
+		fmt.Fprintf(w, "# HELP gocachelogstat_%s %s\n", name, help)
+		fmt.Fprintf(w, "# TYPE gocachelogstat_%s gauge\n", name)
+	}
+	gauge("age_seconds", "Time spanned by the cache log.")
+	fmt.Fprintf(w, "gocachelogstat_age_seconds %d\n", s.Age())
+	gauge("hits", "Number of cache get operations.")
+	fmt.Fprintf(w, "gocachelogstat_hits %d\n", s.Hits.All)
+	gauge("misses", "Number of cache miss operations.")
+	fmt.Fprintf(w, "gocachelogstat_misses %d\n", s.Misses.All)
+	if n := s.Hits.All + s.Misses.All; n > 0 {
+		gauge("hit_ratio", "Fraction of cache lookups that hit.")
+		fmt.Fprintf(w, "gocachelogstat_hit_ratio %g\n", float64(s.Hits.All)/float64(n))
+	}
+
+	gauge("bytes", "Total size of cache entries.")
+	fmt.Fprintf(w, "gocachelogstat_bytes{cache=\"action\"} %d\n", s.Action.Total)
+	fmt.Fprintf(w, "gocachelogstat_bytes{cache=\"data\"} %d\n", s.Data.Total)
+	gauge("reused_bytes", "Total size of cache entries reused at least once.")
+	fmt.Fprintf(w, "gocachelogstat_reused_bytes{cache=\"action\"} %d\n", s.Action.Reused)
+	fmt.Fprintf(w, "gocachelogstat_reused_bytes{cache=\"data\"} %d\n", s.Data.Reused)
+
+	gauge("reuse_age_seconds", "Age of cache entries when reused.")
+	promQuantiles(w, "reuse_age_seconds", "action", s.Action.Reuse)
+	promQuantiles(w, "reuse_age_seconds", "data", s.Data.Reuse)
+	gauge("reuse_delta_seconds", "Time since the previous reuse of cache entries.")
+	promQuantiles(w, "reuse_delta_seconds", "action", s.Action.ReuseDelta)
+	promQuantiles(w, "reuse_delta_seconds", "data", s.Data.ReuseDelta)
+}
+
+// promQuantiles prints the quantiles of the sorted list x
+// as samples of the named metric.
+func promQuantiles(w io.Writer, name, cache string, x []int64) {
+	q := computeQuantiles(x, exportPctiles)
+	if q == nil {
+		return
+	}
+	sample := func(quantile string, v int64) {
+		fmt.Fprintf(w, "gocachelogstat_%s{cache=%q,quantile=%q} %d\n", name, cache, quantile, v)
+	}
+	for _, v := range q.pctiles {
+		sample(strconv.FormatFloat(float64(v.p.num)/float64(v.p.den), 'g', -1, 64), v.value)
+	}
+	sample("1", q.max)
+}
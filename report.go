@@ -0,0 +1,65 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"text/template"
+)
+
+// A Report formats the statistics for a log in each output format.
+// Adding a format means adding a Write method and selecting it
+// in printStats.
+type Report struct {
+	Stats *Stats
+}
+
+// WriteText writes the text report, the default output.
+func (r *Report) WriteText(w io.Writer) {
+	printText(w, r.Stats)
+}
+
+// WriteSummary writes the one-line summary printed by -summary.
+func (r *Report) WriteSummary(w io.Writer) {
+	printSummary(w, r.Stats)
+}
+
+// WriteMarkdown writes the statistics as Markdown tables.
+func (r *Report) WriteMarkdown(w io.Writer) {
+	printMarkdown(w, r.Stats)
+}
+
+// WriteTemplate writes the statistics using the template t,
+// which runs on r.Stats.
+func (r *Report) WriteTemplate(w io.Writer, t *template.Template) {
+	if err := t.Execute(w, r.Stats); err != nil {
+		fatalf("-template: %v", err)
+	}
+}
+
+// WriteJSON writes the statistics as JSON.
+func (r *Report) WriteJSON(w io.Writer) {
+	printJSON(w, newJSONStats(r.Stats))
+}
+
+// WriteCSV writes the statistics as CSV: the reuse events by default,
+// or the table selected by -cumulative, -mrc, or -cdf.
+func (r *Report) WriteCSV(w io.Writer) {
+	switch {
+	case *cumulative:
+		printCumulativeCSV(w, r.Stats)
+	case *mrc:
+		printMRCCSV(w, r.Stats.Accesses, r.Stats.Data.Total)
+	case *cdf > 0:
+		printCDFCSV(w, r.Stats, *cdf)
+	default:
+		printCSV(w, r.Stats)
+	}
+}
+
+// WriteProm writes the statistics in the Prometheus text format.
+func (r *Report) WriteProm(w io.Writer) {
+	printProm(w, r.Stats)
+}
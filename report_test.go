@@ -0,0 +1,171 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testReport returns a Report for testLog, formatted in seconds
+// with the 50th and 90th percentiles. The settings last until t finishes.
+func testReport(t *testing.T) *Report {
+	saveGlobals(t)
+	unitSize = 1
+	*unit = "seconds"
+	*quiet = true
+	tzLoc = time.UTC
+	pctiles = parsePercentiles("percentiles", "50,90")
+	return &Report{Stats: analyzeString(t, testLog)}
+}
+
+func TestReportWriteText(t *testing.T) {
+	r := testReport(t)
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"cache age: 4000.00 seconds\n",
+		"records: 7 (3 put, 3 get, 1 miss, 0 skipped)\n",
+		"action cache: 3 entries,",
+		"data cache: 2 entries,",
+		"\t\t90% ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WriteText output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "```") {
+		t.Errorf("WriteText with -quiet printed markdown fences:\n%s", out)
+	}
+}
+
+func TestReportWriteJSON(t *testing.T) {
+	r := testReport(t)
+	var buf bytes.Buffer
+	r.WriteJSON(&buf)
+	var st jsonStats
+	if err := json.Unmarshal(buf.Bytes(), &st); err != nil {
+		t.Fatalf("WriteJSON output is not JSON: %v\n%s", err, buf.Bytes())
+	}
+	if st.Unit != "seconds" || st.Age != 4000 || st.Hits != 3 || st.Misses != 1 {
+		t.Errorf("WriteJSON = %+v, want seconds, age 4000, 3 hits, 1 miss", st)
+	}
+	if st.Action.Entries != 3 || st.Data.Entries != 2 || st.Data.Reuse["max"] != 4000 {
+		t.Errorf("WriteJSON caches = %+v, %+v", st.Action, st.Data)
+	}
+}
+
+func TestReportWriteCSV(t *testing.T) {
+	r := testReport(t)
+	var buf bytes.Buffer
+	r.WriteCSV(&buf)
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1+2*3 {
+		t.Fatalf("WriteCSV printed %d rows, want header and 6 reuses:\n%v", len(rows), rows)
+	}
+	if strings.Join(rows[0], ",") != "kind,age,delta" {
+		t.Errorf("WriteCSV header = %v", rows[0])
+	}
+	if strings.Join(rows[1], ",") != "action,1000,1000" {
+		t.Errorf("WriteCSV first row = %v, want action,1000,1000", rows[1])
+	}
+}
+
+func TestReportWriteSummary(t *testing.T) {
+	r := testReport(t)
+	var buf bytes.Buffer
+	r.WriteSummary(&buf)
+	if out := buf.String(); !strings.HasPrefix(out, "cache 4000.00 seconds,") || !strings.HasSuffix(out, "hit rate 75.0%\n") {
+		t.Errorf("WriteSummary = %q", out)
+	}
+}
+
+func TestReportWriteMarkdown(t *testing.T) {
+	r := testReport(t)
+	var buf bytes.Buffer
+	r.WriteMarkdown(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"| cache age | 4000.00 seconds |\n",
+		"| action | 3 |",
+		"| 90% | ",
+		"| max | 4000.00 seconds |",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WriteMarkdown output missing %q:\n%s", want, out)
+		}
+	}
+	// Every row of a table, including the delimiter row,
+	// must have as many cells as its header.
+	for _, table := range strings.Split(strings.TrimSpace(out), "\n\n") {
+		lines := strings.Split(table, "\n")
+		if len(lines) < 2 || !strings.HasPrefix(lines[1], "| --- |") {
+			t.Errorf("table missing delimiter row:\n%s", table)
+			continue
+		}
+		n := strings.Count(lines[0], "|")
+		for _, line := range lines {
+			if !strings.HasPrefix(line, "| ") || !strings.HasSuffix(line, " |") || strings.Count(line, "|") != n {
+				t.Errorf("malformed table row %q in:\n%s", line, table)
+			}
+		}
+	}
+}
+
+func TestReportWriteTemplate(t *testing.T) {
+	r := testReport(t)
+	var buf bytes.Buffer
+	r.WriteTemplate(&buf, parseTemplate(`{{.Lines}} lines, {{hitrate .Hits.All .Misses.All}} hits, data p50 {{unit (pctile .Data.Reuse 50)}}`))
+	if out, want := buf.String(), "7 lines, 75.0% hits, data p50 2900.00 seconds\n"; out != want {
+		t.Errorf("WriteTemplate = %q, want %q", out, want)
+	}
+}
+
+func TestReportWriteProm(t *testing.T) {
+	r := testReport(t)
+	var buf bytes.Buffer
+	r.WriteProm(&buf)
+	var samples []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			samples = append(samples, line)
+		}
+	}
+	out := strings.Join(samples, "\n")
+	want := `gocachelogstat_age_seconds 4000
+gocachelogstat_hits 3
+gocachelogstat_misses 1
+gocachelogstat_hit_ratio 0.75
+gocachelogstat_bytes{cache="action"} 462
+gocachelogstat_bytes{cache="data"} 8000
+gocachelogstat_reused_bytes{cache="action"} 308
+gocachelogstat_reused_bytes{cache="data"} 8000
+`
+	if !strings.HasPrefix(out, want) {
+		t.Errorf("WriteProm samples begin:\n%s\nwant:\n%s", out, want)
+	}
+	for _, want := range []string{
+		"# HELP gocachelogstat_hit_ratio Fraction of cache lookups that hit.\n# TYPE gocachelogstat_hit_ratio gauge\n",
+		"\ngocachelogstat_reuse_age_seconds{cache=\"data\",quantile=\"0.5\"} 2900\n",
+		"\ngocachelogstat_reuse_age_seconds{cache=\"data\",quantile=\"0.999\"} 4000\n",
+		"\ngocachelogstat_reuse_age_seconds{cache=\"data\",quantile=\"1\"} 4000\n",
+		"\ngocachelogstat_reuse_delta_seconds{cache=\"action\",quantile=\"0.9\"} 3000\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WriteProm output missing %q:\n%s", want, buf.String())
+		}
+	}
+	if n := strings.Count(buf.String(), "\ngocachelogstat_reuse_delta_seconds{cache=\"data\","); n != len(exportPctiles)+1 {
+		t.Errorf("WriteProm printed %d data reuse delta quantiles, want %d", n, len(exportPctiles)+1)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A scoreComponent is one input to the cache efficiency score.
+type scoreComponent struct {
+	name   string
+	weight float64
+	value  float64 // fraction between 0 and 1
+	ok     bool    // whether value is defined
+}
+
+// efficiencyScore returns the cache efficiency score of s, from 0 to 100,
+// and its components. The score is the weighted mean of:
+//
+//	hit rate: the fraction of gets and misses that were hits
+//	reuse: the fraction of data bytes that were ever reused
+//	no waste: the fraction of data objects that were reused at least once
+//
+// using the weights (in that order), which are normally from -score-weights.
+// A component that is undefined, such as the hit rate of a log with
+// no gets, is left out and the remaining weights are rescaled.
+// If no component is defined, ok is false.
+func efficiencyScore(s *Stats, weights [3]float64) (score float64, components []scoreComponent, ok bool) {
+	ratio := func(part, whole int64) (float64, bool) {
+		if whole == 0 {
+			return 0, false
+		}
+		return float64(part) / float64(whole), true
+	}
+	hit, hitOK := ratio(int64(s.Hits.All), int64(s.Hits.All+s.Misses.All))
+	reuse, reuseOK := ratio(s.Data.Reused, s.Data.Total)
+	waste, wasteOK := ratio(int64(s.Data.Entries-s.Data.NeverReused), int64(s.Data.Entries))
+	components = []scoreComponent{
+		{"hit rate", weights[0], hit, hitOK},
+		{"reuse", weights[1], reuse, reuseOK},
+		{"no waste", weights[2], waste, wasteOK},
+	}
+	var sum, total float64
+	for _, c := range components {
+		if c.ok {
+			sum += c.weight * c.value
+			total += c.weight
+		}
+	}
+	if total == 0 {
+		return 0, components, false
+	}
+	return 100 * sum / total, components, true
+}
+
+// printScore prints the cache efficiency score of s with its components.
+func printScore(w io.Writer, s *Stats, weights [3]float64) {
+	score, components, ok := efficiencyScore(s, weights)
+	if !ok {
+		fmt.Fprintf(w, "efficiency score: n/a\n")
+		return
+	}
+	fmt.Fprintf(w, "efficiency score: %.1f/100\n", score)
+	for _, c := range components {
+		value := "n/a (ignored)"
+		if c.ok {
+			value = fmt.Sprintf("%.1f%%", 100*c.value)
+		}
+		fmt.Fprintf(w, "\t%s: %s, weight %g\n", c.name, value, c.weight)
+	}
+}
+
+// parseScoreWeights parses the value of the named flag,
+// three comma-separated non-negative weights.
+func parseScoreWeights(name, value string) [3]float64 {
+	var weights [3]float64
+	f := strings.Split(value, ",")
+	if len(f) != len(weights) {
+		fatalf("invalid -%s %q: want three comma-separated weights", name, value)
+	}
+	var total float64
+	for i, s := range f {
+		w, err := strconv.ParseFloat(s, 64)
+		if err != nil || w < 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+			fatalf("invalid -%s %q: want three comma-separated weights", name, value)
+		}
+		weights[i] = w
+		total += w
+	}
+	if total == 0 {
+		fatalf("invalid -%s %q: weights are all zero", name, value)
+	}
+	return weights
+}
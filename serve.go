@@ -0,0 +1,60 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// serve serves the statistics for the named logs over HTTP on addr.
+func serve(addr string, files []string) {
+	var mu sync.Mutex
+	var cached *Stats
+	var cachedErr error
+	stats := func() (*Stats, error) {
+		if *interval <= 0 {
+			return loadSources(files)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return cached, cachedErr
+	}
+	if *interval > 0 {
+		cached, cachedErr = loadSources(files)
+		go func() {
+			for range time.Tick(*interval) {
+				s, err := loadSources(files)
+				if err != nil {
+					log.Print(err)
+				}
+				mu.Lock()
+				cached, cachedErr = s, err
+				mu.Unlock()
+			}
+		}()
+	}
+
+	handle := func(path, contentType string, format func(*Report, io.Writer)) {
+		http.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			s, err := stats()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			var buf bytes.Buffer
+			format(&Report{Stats: s}, &buf)
+			w.Header().Set("Content-Type", contentType)
+			w.Write(buf.Bytes())
+		})
+	}
+	handle("/metrics", "text/plain; version=0.0.4", (*Report).WriteProm)
+	handle("/stats.json", "application/json", (*Report).WriteJSON)
+	fatal(http.ListenAndServe(addr, nil))
+}
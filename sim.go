@@ -0,0 +1,370 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"container/heap"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+// simulateMIN replays the accesses under Belady's MIN policy,
+// which, looking ahead in the log, evicts the data entry whose
+// next use is farthest in the future, keeping at most max bytes.
+// As in cachelog.Stats.SimulateLRU, actions are pinned to their data entries and
+// entries larger than max are never cached. With varying entry sizes,
+// MIN is not strictly optimal, but it is the standard upper bound.
+func simulateMIN(accesses []cachelog.Access, max int64) cachelog.SimResult {
+	// next[i] is the index of the next access to the data of accesses[i].
+	next := make([]int, len(accesses))
+	last := make(map[*cachelog.Entry]int)
+	for i := len(accesses) - 1; i >= 0; i-- {
+		d := accesses[i].Entry.Data
+		if j, ok := last[d]; ok {
+			next[i] = j
+		} else {
+			next[i] = len(accesses)
+		}
+		last[d] = i
+	}
+
+	var r cachelog.SimResult
+	var size int64
+	present := make(map[*cachelog.Entry]int) // entry -> index of next use
+	h := new(useHeap)
+	for i, a := range accesses {
+		d := a.Entry.Data
+		_, ok := present[d]
+		r.Count(a, ok)
+		if d.Size > max {
+			continue
+		}
+		if !ok {
+			size += d.Size
+		}
+		present[d] = next[i]
+		heap.Push(h, use{next[i], d})
+		for size > max {
+			u := heap.Pop(h).(use)
+			if n, ok := present[u.e]; !ok || n != u.next {
+				continue // stale
+			}
+			delete(present, u.e)
+			size -= u.e.Size
+			r.Evictions++
+			r.Evicted += u.e.Size
+		}
+		if r.Peak < size {
+			r.Peak = size
+		}
+	}
+	return r
+}
+
+// A use is a scheduled next use of an entry, for simulateMIN.
+type use struct {
+	next int
+	e    *cachelog.Entry
+}
+
+// A useHeap is a max-heap of uses ordered by next use.
+type useHeap []use
+
+func (h useHeap) Len() int            { return len(h) }
+func (h useHeap) Less(i, j int) bool  { return h[i].next > h[j].next }
+func (h useHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *useHeap) Push(x interface{}) { *h = append(*h, x.(use)) }
+func (h *useHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// stackDistances returns, for each access, its LRU stack distance:
+// the total weight of the distinct data entries used since
+// the previous use of the same data entry, including that entry.
+// With a weight of the entry size, the distance is in bytes;
+// with a weight of 1, it counts entries.
+// A first use has distance -1. Under the LRU policy of Stats.SimulateLRU,
+// an access hits in a cache of max bytes if its distance is at most max
+// (exactly so when no entry is larger than max, since SimulateLRU
+// never caches those). Mattson's algorithm computes the distances in
+// one pass, using a Fenwick tree indexed by access to sum the sizes of
+// the entries whose most recent use falls between two accesses.
+func stackDistances(accesses []cachelog.Access, weight func(*cachelog.Entry) int64) []int64 {
+	dist := make([]int64, len(accesses))
+	tree := make([]int64, len(accesses)+1)
+	add := func(i int, v int64) {
+		for i++; i < len(tree); i += i & -i {
+			tree[i] += v
+		}
+	}
+	sum := func(i int) int64 { // sum of positions < i
+		var s int64
+		for ; i > 0; i -= i & -i {
+			s += tree[i]
+		}
+		return s
+	}
+	last := make(map[*cachelog.Entry]int)
+	for i, a := range accesses {
+		d := a.Entry.Data
+		if p, ok := last[d]; ok {
+			dist[i] = sum(i) - sum(p)
+			add(p, -weight(d))
+		} else {
+			dist[i] = -1
+		}
+		add(i, weight(d))
+		last[d] = i
+	}
+	return dist
+}
+
+// mrcPoint is one point of a miss-rate curve.
+type mrcPoint struct {
+	size      int64
+	hits, all int
+}
+
+// missRateCurve returns the simulated LRU hit rate for cache sizes
+// doubling from 1MB until they hold all the data, computed from
+// the stack distances in a single pass over the accesses.
+func missRateCurve(accesses []cachelog.Access, total int64) []mrcPoint {
+	var points []mrcPoint
+	for size := int64(1 << 20); ; size *= 2 {
+		points = append(points, mrcPoint{size: size})
+		if size >= total {
+			break
+		}
+	}
+	dist := stackDistances(accesses, func(e *cachelog.Entry) int64 { return e.Size })
+	for i, a := range accesses {
+		if a.Verb != cachelog.Get && a.Verb != cachelog.Miss {
+			continue
+		}
+		for j := range points {
+			p := &points[j]
+			p.all++
+			if a.Verb == cachelog.Get && dist[i] >= 0 && dist[i] <= p.size {
+				p.hits++
+			}
+		}
+	}
+	return points
+}
+
+// printMRC prints the miss-rate curve as a table with a bar chart
+// of the hit rate at each cache size.
+func printMRC(w io.Writer, accesses []cachelog.Access, total int64) {
+	fmt.Fprintf(w, "lru hit rate by cache size\n")
+	for _, p := range missRateCurve(accesses, total) {
+		bar := ""
+		if p.all > 0 {
+			bar = strings.Repeat("#", p.hits*histWidth/p.all)
+		}
+		fmt.Fprintf(w, "\t%8s %6s %s\n", bytesCell(p.size), hitRate(p.hits, p.all-p.hits), bar)
+	}
+}
+
+// printMRCCSV prints the miss-rate curve as CSV.
+func printMRCCSV(w io.Writer, accesses []cachelog.Access, total int64) {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"size", "hits", "accesses", "hitrate"})
+	for _, p := range missRateCurve(accesses, total) {
+		rate := "0"
+		if p.all > 0 {
+			rate = strconv.FormatFloat(float64(p.hits)/float64(p.all), 'f', 4, 64)
+		}
+		cw.Write([]string{strconv.FormatInt(p.size, 10), strconv.Itoa(p.hits), strconv.Itoa(p.all), rate})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		fatal(err)
+	}
+}
+
+// printStackDistance prints the -percentiles of the reuse distance
+// of the get hits: the number of distinct other data entries
+// used since the previous use of the hit's data entry.
+func printStackDistance(w io.Writer, accesses []cachelog.Access) {
+	dist := stackDistances(accesses, func(*cachelog.Entry) int64 { return 1 })
+	var x []int64
+	for i, a := range accesses {
+		if a.Verb == cachelog.Get && dist[i] >= 0 {
+			x = append(x, dist[i]-1)
+		}
+	}
+	slices.Sort(x)
+	fmt.Fprintf(w, "reuse distance: %d hits\n", len(x))
+	if len(x) == 0 {
+		return
+	}
+	for _, p := range pctiles {
+		fmt.Fprintf(w, "\t%s%% %d entries\n", p.label, percentile(x, p.num, p.den))
+	}
+	fmt.Fprintf(w, "\tmax %d entries\n", x[len(x)-1])
+}
+
+// workingSet returns the maximum and 95th percentile, over all accesses,
+// of the total size of the distinct data entries accessed
+// in the window seconds up to and including each access.
+func workingSet(accesses []cachelog.Access, window int64) (max, p95 int64) {
+	if len(accesses) == 0 {
+		return 0, 0
+	}
+	c := cachelog.NewSimCache()
+	sizes := make([]int64, 0, len(accesses))
+	for _, a := range accesses {
+		for c.Len() > 0 {
+			if _, last := c.Oldest(); a.Time-last <= window {
+				break
+			}
+			c.EvictOldest(nil)
+		}
+		c.Touch(a.Entry.Data, a.Time)
+		sizes = append(sizes, c.Size())
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+	return sizes[len(sizes)-1], sizes[len(sizes)*95/100]
+}
+
+// printWorkingSet prints the working set size for the window of seconds.
+func printWorkingSet(w io.Writer, accesses []cachelog.Access, window int64) {
+	max, p95 := workingSet(accesses, window)
+	fmt.Fprintf(w, "working set over %s: max %s, 95%% %s\n", durationLabel(window), bytesLabel(max), bytesLabel(p95))
+}
+
+// printTTL prints the result of simulating a TTL of ttl seconds.
+func printTTL(w io.Writer, s *Stats, ttl int64) {
+	r := s.SimulateTTL(time.Duration(ttl) * time.Second)
+	fmt.Fprintf(w, "ttl %s: %s hit rate (%d hits, %d lost hits), %d evictions, %s evicted\n",
+		durationLabel(ttl), hitRate(r.Hits, r.Misses), r.Hits, r.Lost, r.Evictions, bytesLabel(r.Evicted))
+}
+
+// printTTLSweep prints a table of the results of simulating
+// each of the TTLs in the list, given in seconds.
+func printTTLSweep(w io.Writer, s *Stats, ttls []int64) {
+	fmt.Fprintf(w, "ttl sweep\n")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\tttl\thit rate\tpeak bytes\t\n")
+	for _, ttl := range ttls {
+		r := s.SimulateTTL(time.Duration(ttl) * time.Second)
+		fmt.Fprintf(tw, "\t%s\t%s\t%s\t\n", durationLabel(ttl), hitRate(r.Hits, r.Misses), bytesCell(r.Peak))
+	}
+	tw.Flush()
+}
+
+// printTargetTTL prints the smallest TTL whose simulated hit rate
+// is at least target, found by binary search over TTLs up to maxTTL seconds.
+func printTargetTTL(w io.Writer, s *Stats, target float64, maxTTL int64) {
+	simulate := func(ttl int64) cachelog.SimResult {
+		return s.SimulateTTL(time.Duration(ttl) * time.Second)
+	}
+	r := simulate(maxTTL)
+	if r.Hits+r.Misses == 0 {
+		fmt.Fprintf(w, "target hit rate %.1f%%: n/a (no gets or misses to simulate)\n", 100*target)
+		return
+	}
+	if r.HitRate() < target {
+		fmt.Fprintf(w, "target hit rate %.1f%%: not achievable with any ttl (at most %s)\n", 100*target, hitRate(r.Hits, r.Misses))
+		return
+	}
+	lo, hi := int64(0), maxTTL // simulated rate < target at lo, >= target at hi
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if m := simulate(mid); m.HitRate() >= target {
+			hi, r = mid, m
+		} else {
+			lo = mid
+		}
+	}
+	fmt.Fprintf(w, "target hit rate %.1f%%: ttl %.2f %s (%s hit rate, peak %s)\n",
+		100*target, float64(hi)/unitSize, *unit, hitRate(r.Hits, r.Misses), bytesLabel(r.Peak))
+}
+
+// A tierResult is the result of simulating a two-tier cache.
+type tierResult struct {
+	hot, cold int // gets served by each tier
+	lost      int // gets that miss both tiers
+	misses    int // misses in the original log
+}
+
+// simulateTiers replays the accesses against a small hot LRU cache
+// of data entries holding at most hot bytes, backed by a cold one
+// holding at most cold bytes. The tiers are exclusive: an entry
+// found in the cold tier is promoted to the hot tier, and entries
+// evicted from the hot tier are demoted to the cold tier.
+// Entries evicted from the cold tier are lost.
+func simulateTiers(accesses []cachelog.Access, hot, cold int64) tierResult {
+	var r tierResult
+	h, c := cachelog.NewSimCache(), cachelog.NewSimCache()
+	for _, a := range accesses {
+		d := a.Entry.Data
+		switch {
+		case h.Has(d):
+			if a.Verb == cachelog.Get {
+				r.hot++
+			}
+		case c.Has(d):
+			if a.Verb == cachelog.Get {
+				r.cold++
+			}
+			c.Remove(d)
+		case a.Verb == cachelog.Get:
+			r.lost++
+		}
+		if a.Verb == cachelog.Miss {
+			r.misses++
+		}
+		if d.Size <= hot {
+			h.Touch(d, a.Time)
+		} else if d.Size <= cold && !c.Has(d) {
+			c.Touch(d, a.Time)
+		}
+		for h.Size() > hot {
+			if e, last := h.EvictOldest(nil); e.Size <= cold {
+				c.Touch(e, last)
+			}
+		}
+		for c.Size() > cold {
+			c.EvictOldest(nil)
+		}
+	}
+	return r
+}
+
+// printTiers prints the result of simulating a two-tier cache.
+func printTiers(w io.Writer, accesses []cachelog.Access, hot, cold int64) {
+	r := simulateTiers(accesses, hot, cold)
+	n := int64(r.hot + r.cold + r.lost + r.misses)
+	fmt.Fprintf(w, "two-tier cache (hot %s, cold %s): %s hot hits, %s cold hits, %s rebuilt (%d lost hits, %d misses)\n",
+		bytesLabel(hot), bytesLabel(cold), percent(int64(r.hot), n), percent(int64(r.cold), n), percent(int64(r.lost+r.misses), n), r.lost, r.misses)
+}
+
+// printLRU prints the result of simulating an LRU cache of max bytes.
+func printLRU(w io.Writer, s *Stats, max int64) {
+	r := s.SimulateLRU(max)
+	fmt.Fprintf(w, "lru cap %s: %s hit rate (%d hits, %d lost hits), %d evictions, %s evicted\n",
+		bytesLabel(max), hitRate(r.Hits, r.Misses), r.Hits, r.Lost, r.Evictions, bytesLabel(r.Evicted))
+}
+
+// printMIN prints the result of simulating Belady's MIN policy
+// with a cache of max bytes, an upper bound for printLRU.
+func printMIN(w io.Writer, accesses []cachelog.Access, max int64) {
+	r := simulateMIN(accesses, max)
+	fmt.Fprintf(w, "optimal (belady) cap %s: %s hit rate (%d hits, %d lost hits), %d evictions, %s evicted\n",
+		bytesLabel(max), hitRate(r.Hits, r.Misses), r.Hits, r.Lost, r.Evictions, bytesLabel(r.Evicted))
+}
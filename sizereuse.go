@@ -0,0 +1,51 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// printSizeReuse prints a table of the data objects in s
+// bucketed by size as in printSizeHist, giving for each bucket
+// the fraction of objects ever reused and their mean number of reuses.
+func printSizeReuse(w io.Writer, s *Stats) {
+	type bucket struct {
+		objects, reused, reuses int64
+	}
+	var buckets []bucket
+	for _, e := range s.Outputs {
+		i := 0
+		for hi := int64(1024); e.Size >= hi; hi *= 4 {
+			i++
+		}
+		for len(buckets) <= i {
+			buckets = append(buckets, bucket{})
+		}
+		b := &buckets[i]
+		b.objects++
+		b.reuses += int64(e.Reuses)
+		if e.Reused {
+			b.reused++
+		}
+	}
+
+	fmt.Fprintf(w, "reuse by data object size\n")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\tsize\tobjects\treused\tmean reuses\t\n")
+	lo := int64(0)
+	for i, hi := 0, int64(1024); i < len(buckets); i, hi = i+1, hi*4 {
+		b := buckets[i]
+		mean := "n/a"
+		if b.objects > 0 {
+			mean = fmt.Sprintf("%.2f", float64(b.reuses)/float64(b.objects))
+		}
+		fmt.Fprintf(tw, "\t%s-%s\t%d\t%s\t%s\t\n", bytesCell(lo), bytesCell(hi), b.objects, percent(b.reused, b.objects), mean)
+		lo = hi
+	}
+	tw.Flush()
+}
@@ -0,0 +1,32 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// A sourceStats holds the statistics for one of several merged logs.
+type sourceStats struct {
+	name string
+	s    *Stats
+}
+
+// printSources prints a table of the statistics for each log.
+// Entries put in more than one log are counted in each,
+// so the rows may add up to more than the merged totals.
+func printSources(w io.Writer, sources []sourceStats) {
+	fmt.Fprintf(w, "by source\n")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\tlog\tage (%s)\trecords\thit rate\tdata bytes\treused\t\n", *unit)
+	for _, src := range sources {
+		s := src.s
+		fmt.Fprintf(tw, "\t%s\t%.2f\t%d\t%s\t%s\t%s\t\n", src.name, float64(s.Age())/unitSize, s.Lines,
+			hitRate(s.Hits.All, s.Misses.All), bytesCell(s.Data.Total), percent(s.Data.Reused, s.Data.Total))
+	}
+	tw.Flush()
+}
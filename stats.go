@@ -0,0 +1,32 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+// Stats holds the statistics printed by the command:
+// those computed by cachelog.Analyze, along with
+// what the command itself filtered out.
+type Stats struct {
+	*cachelog.Stats
+
+	Read          int   // number of records read, before any filtering
+	Excluded      int   // number of records outside the time window
+	Filtered      int   // number of data objects excluded by size
+	FilteredBytes int64 // total size of the excluded data objects
+	FilteredLines int   // number of records excluded by size
+
+	sources []sourceStats // per-log statistics, for -by-source
+}
+
+// Analyze computes statistics for the records,
+// using the -action-size estimate for action entries
+// and clamping skewed reuse times if -clamp-skew is set.
+func Analyze(records []cachelog.Record) *Stats {
+	a := &cachelog.Analyzer{ActionSize: *actionSize, ClampSkew: *clampSkew}
+	return &Stats{Stats: a.Analyze(records), Read: len(records)}
+}
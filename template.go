@@ -0,0 +1,82 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+)
+
+// tmpl is the parsed -template, or nil.
+var tmpl *template.Template
+
+const templateHelp = `
+The -template flag prints the statistics using a Go text/template,
+given inline or read from a file as @path. A newline is added if the
+template does not end in one. The template runs on the statistics,
+with fields including:
+
+	.Start, .End        times of the first and last records (Unix seconds)
+	.Age                time spanned by the log, in seconds
+	.Lines, .Puts       number of records analyzed and of puts
+	.Read               number of records read, before any filtering
+	.Skipped, .Trims    number of unrecognized records and of trims
+	.Hits, .Misses      get and miss counts: .All for every operation,
+	                    .Known for actions put in the log
+	.Action, .Data      cache statistics, each with fields
+	                    .Entries, .Total, .Reused, .NeverReused,
+	                    .NeverReusedBytes, and the sorted lists
+	                    .Reuse, .ReuseDelta, and .Sizes
+	.SpanDays           number of days spanned by the log
+	.Excluded           records outside the -since, -until, or -tail window
+	.Filtered           data objects excluded by -min-size and -max-size
+
+and functions:
+
+	bytes n             size as printed by the text report
+	percent part whole  part as a percentage of whole
+	hitrate hits misses hit rate as a percentage
+	pctile list p       p'th percentile of a sorted list
+	unit secs           time in the -unit, with the unit name
+
+For example:
+
+	-template '{{.Hits.All}} hits, {{.Misses.All}} misses'
+	-template 'data {{bytes .Data.Total}}, p90 reuse {{unit (pctile .Data.Reuse 90)}}'
+`
+
+var templateFuncs = template.FuncMap{
+	"bytes":   bytesLabel,
+	"percent": percent,
+	"hitrate": hitRate,
+	"pctile": func(x []int64, p int) int64 {
+		return percentile(x, p, 100)
+	},
+	"unit": func(secs int64) string {
+		return fmt.Sprintf("%.2f %s", float64(secs)/unitSize, *unit)
+	},
+}
+
+// parseTemplate parses the -template flag value text,
+// reading the template from a file if text is @path.
+func parseTemplate(text string) *template.Template {
+	if strings.HasPrefix(text, "@") {
+		data, err := ioutil.ReadFile(text[1:])
+		if err != nil {
+			fatalf("-template: %v", err)
+		}
+		text = string(data)
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	t, err := template.New("template").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		fatalf("-template: %v", err)
+	}
+	return t
+}
@@ -0,0 +1,235 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+// dayLabel returns the UTC date of day number d.
+func dayLabel(d int64) string {
+	return time.Unix(d*86400, 0).UTC().Format("2006-01-02")
+}
+
+// timelineDays returns the statistics for each day spanned by s,
+// including days with no activity.
+func timelineDays(s *Stats) []*cachelog.DayStats {
+	if s.Lines == 0 {
+		return nil
+	}
+	var days []*cachelog.DayStats
+	for d := cachelog.Day(s.Start); d <= cachelog.Day(s.End); d++ {
+		ds := s.Days[d]
+		if ds == nil {
+			ds = new(cachelog.DayStats)
+		}
+		days = append(days, ds)
+	}
+	return days
+}
+
+// printTimeline prints the number of bytes added to the data cache
+// and the number of operations on each day spanned by the log,
+// along with the churn: the fraction of bytes added that day
+// that were never reused, and the ratio of puts to gets.
+// If the log records trims, the table also shows the trims
+// on each day and the bytes they reclaimed.
+// Days whose put/get ratio exceeds -put-get-threshold, if set,
+// are marked with an asterisk.
+func printTimeline(w io.Writer, s *Stats) {
+	days := timelineDays(s)
+	first := cachelog.Day(s.Start)
+	var labels []string
+	var added []int64
+	for i, ds := range days {
+		labels = append(labels, dayLabel(first+int64(i)))
+		added = append(added, ds.Added)
+	}
+	fmt.Fprintf(w, "bytes added per day\n")
+	printBytesHist(w, labels, added)
+
+	fmt.Fprintf(w, "activity per day\n")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	if s.Trims == 0 {
+		fmt.Fprintf(tw, "\tday\tput\tget\tmiss\tchurn\tput/get\t\n")
+	} else {
+		fmt.Fprintf(tw, "\tday\tput\tget\tmiss\tchurn\tput/get\ttrim\ttrimmed\t\n")
+	}
+	flagged := 0
+	for i, ds := range days {
+		ratio := "n/a"
+		if ds.Gets > 0 {
+			ratio = fmt.Sprintf("%.2f", float64(ds.Puts)/float64(ds.Gets))
+		}
+		if putGetHigh(ds, *putGetThreshold) {
+			ratio += "*"
+			flagged++
+		}
+		fmt.Fprintf(tw, "\t%s\t%d\t%d\t%d\t%s\t%s\t", labels[i], ds.Puts, ds.Gets, ds.Misses, percent(ds.Churned, ds.Added), ratio)
+		if s.Trims > 0 {
+			fmt.Fprintf(tw, "%d\t%s\t", ds.Trims, bytesCell(ds.Trimmed))
+		}
+		fmt.Fprintf(tw, "\n")
+	}
+	tw.Flush()
+	if flagged > 0 {
+		fmt.Fprintf(w, "\t* %d days with put/get ratio above %v\n", flagged, *putGetThreshold)
+	}
+}
+
+// putGetHigh reports whether the put/get ratio of ds exceeds threshold,
+// counting puts with no gets as exceeding any threshold.
+// A threshold of zero disables the check.
+func putGetHigh(ds *cachelog.DayStats, threshold float64) bool {
+	if threshold <= 0 || ds.Puts == 0 {
+		return false
+	}
+	return ds.Gets == 0 || float64(ds.Puts)/float64(ds.Gets) > threshold
+}
+
+// printInterarrival prints the percentiles of the times
+// between consecutive get operations, regardless of entry.
+func printInterarrival(w io.Writer, s *Stats) {
+	var gaps []int64
+	last := int64(-1)
+	for _, o := range s.Ops {
+		if o.Verb != cachelog.Get {
+			continue
+		}
+		if last >= 0 {
+			gaps = append(gaps, o.Time-last)
+		}
+		last = o.Time
+	}
+	slices.Sort(gaps)
+	fmt.Fprintf(w, "get inter-arrival times: %d gaps\n", len(gaps))
+	printPercentiles(w, "inter-arrival time", gaps)
+}
+
+// printHours prints a histogram of the operations in the log
+// by hour of the day in the location loc.
+func printHours(w io.Writer, s *Stats, loc *time.Location) {
+	labels := make([]string, 24)
+	counts := make([]int64, 24)
+	for h := range labels {
+		labels[h] = fmt.Sprintf("%02d:00", h)
+	}
+	for _, o := range s.Ops {
+		counts[time.Unix(o.Time, 0).In(loc).Hour()]++
+	}
+	fmt.Fprintf(w, "operations by hour of day (%s)\n", loc)
+	printHist(w, labels, counts)
+}
+
+// printWeekdays prints the number of operations and the bytes
+// added to the data cache by day of the week in the location loc.
+func printWeekdays(w io.Writer, s *Stats, loc *time.Location) {
+	var ops, added [7]int64
+	for _, o := range s.Ops {
+		d := time.Unix(o.Time, 0).In(loc).Weekday()
+		ops[d]++
+		added[d] += o.Added
+	}
+	fmt.Fprintf(w, "activity by day of week (%s)\n", loc)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\tday\tops\t\tbytes added\t\t\n")
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		fmt.Fprintf(tw, "\t%s\t%d\t%s\t%s\t%s\t\n", d, ops[d], percent(ops[d], int64(len(s.Ops))), bytesCell(added[d]), percent(added[d], s.Data.Total))
+	}
+	tw.Flush()
+}
+
+// printCumulative prints the cumulative bytes of data entries reused
+// by the end of each day spanned by the log, followed by a table
+// of the cumulative action and data bytes reused.
+func printCumulative(w io.Writer, s *Stats) {
+	days := timelineDays(s)
+	first := cachelog.Day(s.Start)
+	var labels []string
+	var action, data []int64
+	var a, d int64
+	for i, ds := range days {
+		a += ds.ActionReused
+		d += ds.DataReused
+		labels = append(labels, dayLabel(first+int64(i)))
+		action = append(action, a)
+		data = append(data, d)
+	}
+	fmt.Fprintf(w, "cumulative data bytes reused\n")
+	printBytesHist(w, labels, data)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\tday\taction\tdata\t\n")
+	for i := range days {
+		fmt.Fprintf(tw, "\t%s\t%s\t%s\t\n", labels[i], bytesCell(action[i]), bytesCell(data[i]))
+	}
+	tw.Flush()
+}
+
+// printWaste prints, for each day spanned by the log, the bytes
+// of data entries added that day and never reused in the rest of the log,
+// along with the running totals. The wasted bytes are an upper bound
+// on what a policy that knew the future could have avoided caching.
+func printWaste(w io.Writer, s *Stats) {
+	days := timelineDays(s)
+	first := cachelog.Day(s.Start)
+	fmt.Fprintf(w, "wasted data bytes per day (never reused)\n")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\tday\tadded\twasted\t\ttotal wasted\t\t\n")
+	var added, wasted int64
+	for i, ds := range days {
+		added += ds.Added
+		wasted += ds.Churned
+		fmt.Fprintf(tw, "\t%s\t%s\t%s\t%s\t%s\t%s\t\n", dayLabel(first+int64(i)),
+			bytesCell(ds.Added), bytesCell(ds.Churned), percent(ds.Churned, ds.Added),
+			bytesCell(wasted), percent(wasted, added))
+	}
+	tw.Flush()
+}
+
+// printProjection prints an estimate of the total and reused data bytes
+// in the cache n days after the end of the log, extrapolating
+// a least-squares linear fit of the cumulative daily totals.
+func printProjection(w io.Writer, s *Stats, n int) {
+	days := timelineDays(s)
+	if len(days) < 2 {
+		fmt.Fprintf(w, "projection: log too short to fit a trend (need at least 2 days)\n")
+		return
+	}
+	var total, reused []float64
+	var t, r int64
+	for _, ds := range days {
+		t += ds.Added
+		r += ds.DataReused
+		total = append(total, float64(t))
+		reused = append(reused, float64(r))
+	}
+	x := float64(len(days) - 1 + n)
+	fmt.Fprintf(w, "projection (linear estimate) in %d days: %s data bytes, %s reused\n",
+		n, bytesCell(int64(math.Floor(linearFit(total, x)+0.5))), bytesCell(int64(math.Floor(linearFit(reused, x)+0.5))))
+}
+
+// linearFit fits a least-squares line to the points (i, y[i])
+// and returns its value at x.
+func linearFit(y []float64, x float64) float64 {
+	var sx, sy, sxx, sxy float64
+	for i, v := range y {
+		fi := float64(i)
+		sx += fi
+		sy += v
+		sxx += fi * fi
+		sxy += fi * v
+	}
+	n := float64(len(y))
+	slope := (n*sxy - sx*sy) / (n*sxx - sx*sx)
+	return (sy - slope*sx + slope*n*x) / n
+}
@@ -0,0 +1,75 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+// A dataObject is a data cache entry and its output ID.
+type dataObject struct {
+	id string
+	e  *cachelog.Entry
+}
+
+// dataObjects returns the data cache entries, keyed by output ID,
+// in order of decreasing size.
+func dataObjects(outputs map[string]*cachelog.Entry) []dataObject {
+	var objs []dataObject
+	for id, e := range outputs {
+		objs = append(objs, dataObject{id, e})
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		if objs[i].e.Size != objs[j].e.Size {
+			return objs[i].e.Size > objs[j].e.Size
+		}
+		return objs[i].id < objs[j].id
+	})
+	return objs
+}
+
+// printTop prints the n largest data cache entries,
+// in order of decreasing size.
+func printTop(w io.Writer, outputs map[string]*cachelog.Entry, n int) {
+	objs := dataObjects(outputs)
+	if len(objs) > n {
+		objs = objs[:n]
+	}
+
+	fmt.Fprintf(w, "largest data objects\n")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\tsize\tcreated\treused\thash\t\n")
+	for _, o := range objs {
+		created := time.Unix(o.e.Created, 0).In(tzLoc).Format(time.RFC3339)
+		fmt.Fprintf(tw, "\t%s\t%s\t%v\t%s\t\n", bytesCell(o.e.Size), created, o.e.Reused, o.id)
+	}
+	tw.Flush()
+}
+
+// printLarge prints the data cache entries larger than min bytes,
+// in order of decreasing size, with the number of times each was reused.
+func printLarge(w io.Writer, outputs map[string]*cachelog.Entry, min int64) {
+	objs := dataObjects(outputs)
+	n := sort.Search(len(objs), func(i int) bool { return objs[i].e.Size <= min })
+	objs = objs[:n]
+
+	fmt.Fprintf(w, "data objects larger than %s: %d\n", bytesLabel(min), len(objs))
+	if len(objs) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\tsize\tcreated\treuses\thash\t\n")
+	for _, o := range objs {
+		created := time.Unix(o.e.Created, 0).In(tzLoc).Format(time.RFC3339)
+		fmt.Fprintf(tw, "\t%s\t%s\t%d\t%s\t\n", bytesCell(o.e.Size), created, o.e.Reuses, o.id)
+	}
+	tw.Flush()
+}
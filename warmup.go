@@ -0,0 +1,74 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+// warmupTime replays the gets and misses in ops against a cache
+// that starts empty and never evicts, so that a get is a hit only
+// if its action was put earlier in the log. It computes the hit rate
+// over a sliding window of the given length, in seconds, and returns
+// the time of the first operation at which a full window's hit rate
+// reaches frac times the final window's hit rate, along with that final rate.
+// It returns ok=false if the log is shorter than the window
+// or there are no hits to warm up to.
+func warmupTime(ops []cachelog.Op, window int64, frac float64) (t int64, final float64, ok bool) {
+	var times []int64
+	var hits []bool
+	for _, o := range ops {
+		if o.Verb == cachelog.Get || o.Verb == cachelog.Miss {
+			times = append(times, o.Time)
+			hits = append(hits, o.Verb == cachelog.Get && o.Known)
+		}
+	}
+	if len(times) == 0 || times[len(times)-1]-times[0] < window {
+		return 0, 0, false
+	}
+
+	// rates[i] is the hit rate over the window ending at times[i].
+	rates := make([]float64, len(times))
+	lo, n := 0, 0
+	for i, t := range times {
+		if hits[i] {
+			n++
+		}
+		for times[lo] <= t-window {
+			if hits[lo] {
+				n--
+			}
+			lo++
+		}
+		rates[i] = float64(n) / float64(i-lo+1)
+	}
+	final = rates[len(rates)-1]
+	if final == 0 {
+		return 0, 0, false
+	}
+	for i, t := range times {
+		if t-times[0] >= window && rates[i] >= frac*final {
+			return t, final, true
+		}
+	}
+	return times[len(times)-1], final, true
+}
+
+// printWarmup prints how long a cold cache replaying s takes
+// to reach frac of its final hit rate over a sliding window.
+func printWarmup(w io.Writer, s *Stats, window int64, frac float64) {
+	t, final, ok := warmupTime(s.Ops, window, frac)
+	if !ok {
+		fmt.Fprintf(w, "warm-up (%s window): not enough data\n", durationLabel(window))
+		return
+	}
+	fmt.Fprintf(w, "warm-up (%s window): %.2f %s to reach %.0f%% of the final %.1f%% hit rate, at %s\n",
+		durationLabel(window), float64(t-s.Start)/unitSize, *unit, 100*frac, 100*final,
+		time.Unix(t, 0).In(tzLoc).Format(time.RFC3339))
+}
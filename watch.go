@@ -0,0 +1,96 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"rsc.io/gocachelogstat/cachelog"
+)
+
+// A tailer incrementally reads the records of a growing log file.
+type tailer struct {
+	file    string
+	offset  int64 // offset of first unread byte
+	lines   int   // number of lines before offset
+	parser  *cachelog.Parser
+	records []cachelog.Record
+}
+
+func newTailer(file string) *tailer {
+	return &tailer{
+		file:   file,
+		parser: &cachelog.Parser{Name: file, Source: file, Warn: warn, Lenient: *lenient},
+	}
+}
+
+// update reads any complete lines added to the log since the last update.
+// If the file has shrunk, update starts over from the beginning.
+// A gzip-compressed log is an error.
+func (t *tailer) update() error {
+	f, err := os.Open(t.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < t.offset {
+		t.offset, t.lines = 0, 0
+		t.parser.Malformed = 0
+		t.records = nil
+	}
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	// Parse reads gzip only from the start of a stream,
+	// so a compressed log cannot be followed.
+	if t.offset == 0 && len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		return fmt.Errorf("%s: -watch cannot follow a gzip-compressed log", t.file)
+	}
+	// Leave any partial last line for the next update.
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+	t.parser.LineOffset = t.lines
+	list, err := t.parser.Parse(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	t.records = append(t.records, list...)
+	t.offset += int64(len(data))
+	t.lines += t.parser.Lines
+	return nil
+}
+
+// watchLog prints the statistics for the named log every -watch interval,
+// clearing the screen before each update.
+func watchLog(file string) {
+	t := newTailer(file)
+	for {
+		if err := t.update(); err != nil {
+			fatal(err)
+		}
+		w := bufio.NewWriter(os.Stdout)
+		fmt.Fprintf(w, "\x1b[H\x1b[2J")
+		s := analyzeRecords(t.records)
+		s.Malformed = t.parser.Malformed
+		printStats(w, s)
+		if err := w.Flush(); err != nil {
+			fatal(err)
+		}
+		time.Sleep(*watch)
+	}
+}